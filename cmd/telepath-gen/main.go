@@ -0,0 +1,133 @@
+// Command telepath-gen writes a TypeScript declaration file describing the JS constructors
+// registered on the global registry by the given packages, or with -manifest a JSON manifest
+// of the constructors, their Go types, media and argument schemas.
+//
+// Usage:
+//
+//	telepath-gen [-o telepath.d.ts] ./adapters ...
+//	telepath-gen -manifest [-o telepath.json] ./adapters ...
+//
+// The packages are imported by a temporary program in the current module,
+// which is run to walk the registry after the packages have registered their adapters.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+var program = template.Must(template.New("main.go").Parse(`// Code generated by telepath-gen. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Nigel2392/go-telepath/telepath"
+{{- range .Packages }}
+	_ {{ printf "%q" . }}
+{{- end }}
+)
+
+func main() {
+	if err := telepath.GlobalRegistry.{{ .Method }}(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`))
+
+func main() {
+	var output = flag.String("o", "", "file to write the output to, defaults to stdout")
+	var manifest = flag.Bool("manifest", false, "write a JSON manifest instead of TypeScript declarations")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: telepath-gen [-manifest] [-o file] packages...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var method = "WriteTypeScript"
+	if *manifest {
+		method = "WriteManifest"
+	}
+
+	var result, err = generate(flag.Args(), method)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "telepath-gen:", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(result)
+		return
+	}
+
+	if err = os.WriteFile(*output, result, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "telepath-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func generate(patterns []string, method string) ([]byte, error) {
+	var packages, err = importPaths(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	// The program has to live inside of the current module to be able to import its packages.
+	dir, err := os.MkdirTemp(".", ".telepath-gen-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var source bytes.Buffer
+	var data = struct {
+		Packages []string
+		Method   string
+	}{packages, method}
+
+	if err = program.Execute(&source, data); err != nil {
+		return nil, err
+	}
+
+	var file = filepath.Join(dir, "main.go")
+	if err = os.WriteFile(file, source.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	var cmd = exec.Command("go", "run", file)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running generator: %w", err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// importPaths resolves package patterns like ./adapters to their import paths.
+func importPaths(patterns []string) ([]string, error) {
+	var args = append([]string{"list", "-f", "{{.ImportPath}}"}, patterns...)
+	var out, err = exec.Command("go", args...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("listing packages: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
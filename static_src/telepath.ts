@@ -3,24 +3,166 @@
 // Huge thanks to https://github.com/wagtail/telepath-unpack for the original implementation of this code.
 // Mainly to Matt Westcott @gasman for writing it.
 
+/* only available in ES2020 and later */
+declare const BigInt: (value: string) => any;
+
+const BASE64_CHARS = 'ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/';
+
+function decodeBase64(data: string): Uint8Array {
+	/* not using atob, it is not available in every runtime */
+	const clean = data.replace(/=+$/, '');
+	const bytes = new Uint8Array(Math.floor(clean.length * 3 / 4));
+	let buffer = 0, bits = 0, index = 0;
+	for (let i = 0; i < clean.length; i++) {
+		buffer = (buffer << 6) | BASE64_CHARS.indexOf(clean[i]);
+		bits += 6;
+		if (bits >= 8) {
+			bits -= 8;
+			bytes[index++] = (buffer >> bits) & 0xff;
+		}
+	}
+	return bytes;
+}
+
+/* go-telepath's FORMAT_VERSION_VERBOSE and FORMAT_VERSION_COMPACT */
+const FORMAT_VERSION_VERBOSE = 1;
+const FORMAT_VERSION_COMPACT = 2;
+
+/* compact aliases of the reserved keys, go-telepath's COMPACT_KEYS */
+const COMPACT_KEYS: {[key: string]: string} = {
+	'_args': '_a',
+	'_dict': '_d',
+	'_id': '_i',
+	'_list': '_l',
+	'_ref': '_r',
+	'_type': '_t',
+	'_val': '_v',
+};
+
+type ValidationErrorMessage = {message: string, code: string, params: {[key: string]: any}};
+
+/* packed by go-telepath's ValidationErrorTelepathAdapter */
+class ValidationError {
+	errors: ValidationErrorMessage[];
+	fieldErrors: {[field: string]: ValidationError};
+	blockErrors: {[index: string]: ValidationError};
+
+	constructor(errors: ValidationErrorMessage[], fieldErrors: {[field: string]: ValidationError}, blockErrors: {[index: string]: ValidationError}) {
+		this.errors = errors || [];
+		this.fieldErrors = fieldErrors || {};
+		this.blockErrors = blockErrors || {};
+	}
+
+	get messages(): string[] {
+		return this.errors.map(error => error.message);
+	}
+
+	toString(): string {
+		return this.messages.join(' ');
+	}
+}
+
 class Telepath {
+	static ValidationError = ValidationError;
+
 	constructors: {[key: string]: any};
+	factories: {[key: string]: any};
 
 	constructor() {
 	  	this.constructors = {};
+		this.factories = {};
+
+		/* packed by go-telepath's BYTE_ENCODING_UINT8ARRAY */
+		this.registerFactory('telepath.Uint8Array', decodeBase64);
+		/* packed by go-telepath's FLOAT_POLICY_MARKER, one of "NaN", "Infinity" or "-Infinity" */
+		this.registerFactory('telepath.Float', (value: string) => Number(value));
+		/* packed by go-telepath's BIGINT_POLICY_BIGINT, kept as a string if BigInt is not supported */
+		this.registerFactory('telepath.BigInt', (value: string) => typeof BigInt === 'function' ? BigInt(value) : value);
+
+		this.register('telepath.ValidationError', ValidationError);
 	}
   
 	register(name: any, constructor: any) {
 	  	this.constructors[name] = constructor;
 	}
+
+	registerFactory(name: any, factory: any) {
+		/* factories are called without `new`, so they can return any value */
+		this.factories[name] = factory;
+	}
   
 	unpack(objData: any) {
+		objData = this.expandFormat(objData);
 	  	const packedValuesById: {[key: number]: any} = {};
 	  	this.scanForIds(objData, packedValuesById);
 	  	const valuesById = {};
 	  	return this.unpackWithRefs(objData, packedValuesById, valuesById);
 	}
   
+	expandFormat(objData: any): any {
+		/* values packed in a format envelope have a _format version, others are in the verbose format */
+		if (objData === null || typeof(objData) !== 'object' || Array.isArray(objData) || !('_format' in objData)) {
+			return objData;
+		}
+
+		switch (objData['_format']) {
+		case FORMAT_VERSION_VERBOSE:
+			return objData['_value'];
+		case FORMAT_VERSION_COMPACT:
+			return this.expandCompact(objData['_value'], objData['_types'] || []);
+		default:
+			throw new Error('telepath unpack found unsupported format version: ' + objData['_format']);
+		}
+	}
+
+	expandCompact(objData: any, types: string[]): any {
+		/* convert a value in the compact format to the verbose format */
+		if (objData === null || typeof(objData) !== 'object') {
+			return objData;
+		}
+
+		if (Array.isArray(objData)) {
+			return objData.map(item => this.expandCompact(item, types));
+		}
+
+		const isReserved = Object.keys(COMPACT_KEYS).some(key => COMPACT_KEYS[key] in objData);
+		const result: {[key: string]: any} = {};
+
+		if (!isReserved) {
+			for (const [key, val] of Object.entries(objData)) {
+				result[key] = this.expandCompact(val, types);
+			}
+			return result;
+		}
+
+		for (const [verbose, short] of Object.entries(COMPACT_KEYS)) {
+			if (!(short in objData)) {
+				continue;
+			}
+
+			const val = objData[short];
+			if (verbose === '_type') {
+				if (!(Number.isInteger(val) && val >= 0 && val < types.length)) {
+					throw new Error('telepath unpack found unknown constructor index: ' + val);
+				}
+				result[verbose] = types[val];
+			} else if (verbose === '_args' || verbose === '_list') {
+				result[verbose] = this.expandCompact(val, types);
+			} else if (verbose === '_dict') {
+				/* the keys of a _dict are never reserved, only its values are expanded */
+				const dict: {[key: string]: any} = {};
+				for (const [key, item] of Object.entries(val)) {
+					dict[key] = this.expandCompact(item, types);
+				}
+				result[verbose] = dict;
+			} else {
+				/* _val is never unpacked, _id and _ref are numbers */
+				result[verbose] = val;
+			}
+		}
+		return result;
+	}
+
 	scanForIds(objData: any, packedValuesById: {[key: number]: any}) {
 	  	/* descend into objData, indexing any objects with an _id in packedValuesById */
 		
@@ -120,15 +262,19 @@ class Telepath {
 	  } else if ('_type' in objData) {
 		/* handle as a custom type */
 		const constructorId = objData['_type'];
-		if (!(constructorId in this.constructors)) {
+		if (!(constructorId in this.constructors) && !(constructorId in this.factories)) {
 		  throw new Error('telepath unpack found unknown constructor id: ' + constructorId);
 		}
-		const constructor = this.constructors[constructorId];
 		/* unpack arguments recursively */
 		const args = objData['_args'].map(function(arg: any) {
 			return this.unpackWithRefs(arg, packedValuesById, valuesById)
 		}.bind(this));
-		result = new constructor(...args);
+		if (constructorId in this.constructors) {
+		  const constructor = this.constructors[constructorId];
+		  result = new constructor(...args);
+		} else {
+		  result = this.factories[constructorId](...args);
+		}
 	  } else if ('_id' in objData) {
 		throw new Error('telepath encountered object with _id but no type specified');
 	  } else {
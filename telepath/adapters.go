@@ -2,8 +2,13 @@ package telepath
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 )
 
 type BaseTelepathAdapter struct{}
@@ -13,7 +18,11 @@ func BaseAdapter() *BaseTelepathAdapter {
 }
 
 func (m *BaseTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
-	return NewTelepathValueNode(value), nil
+	var floatPolicy, bigIntPolicy = numberPolicies(c)
+	if node, ok := buildNumberNode(value, floatPolicy, bigIntPolicy); ok {
+		return node, nil
+	}
+	return NewPrimitiveNode(value), nil
 }
 
 type UUIDTelepathAdapter struct{}
@@ -56,22 +65,147 @@ func (m *SliceTelepathAdapter) BuildNode(ctx context.Context, value any, c Conte
 		return nil, fmt.Errorf("value is not a slice")
 	}
 
-	var nodes = make([]Node, 0, rVal.Len())
-	for i := 0; i < rVal.Len(); i++ {
-		var (
-			item      = rVal.Index(i).Interface()
-			node, err = c.BuildNode(ctx, item)
-		)
+	if node, ok := buildPrimitiveList(c, value); ok {
+		if err := countNodes(ctx, c, rVal.Len()); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	var nodes, err = buildSliceNodes(ctx, rVal, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewListNode(nodes), nil
+
+}
+
+type ByteEncoding int
 
+const (
+	BYTE_ENCODING_LIST       ByteEncoding = iota // A list of numbers
+	BYTE_ENCODING_BASE64                         // A standard base64 encoded string
+	BYTE_ENCODING_HEX                            // A lowercase hex encoded string
+	BYTE_ENCODING_UINT8ARRAY                     // A BYTES_JS_CONSTRUCTOR object with the base64 encoded bytes as its argument
+	BYTE_ENCODING_RAW_JSON                       // The bytes are valid JSON which is emitted as-is
+)
+
+const BYTES_JS_CONSTRUCTOR = "telepath.Uint8Array" // Registered by default in the bundled telepath.js
+
+func (e ByteEncoding) Encode(b []byte) (string, error) {
+	switch e {
+	case BYTE_ENCODING_BASE64, BYTE_ENCODING_UINT8ARRAY:
+		return base64.StdEncoding.EncodeToString(b), nil
+	case BYTE_ENCODING_HEX:
+		return hex.EncodeToString(b), nil
+	}
+	return "", fmt.Errorf("byte encoding %d does not encode to a string", e)
+}
+
+func newBytesNode(ctx context.Context, c Context, b []byte, encoding ByteEncoding) (Node, error) {
+	switch encoding {
+	case BYTE_ENCODING_LIST:
+		var values = make([]int, len(b))
+		for i, v := range b {
+			values[i] = int(v)
+		}
+		if err := countNodes(ctx, c, len(values)); err != nil {
+			return nil, err
+		}
+		return NewPrimitiveListNode(values), nil
+	case BYTE_ENCODING_BASE64, BYTE_ENCODING_HEX:
+		var s, err = encoding.Encode(b)
+		if err != nil {
+			return nil, err
+		}
+		return NewPrimitiveNode(s), nil
+	case BYTE_ENCODING_UINT8ARRAY:
+		var s, err = encoding.Encode(b)
 		if err != nil {
 			return nil, err
 		}
+		return NewObjectNode(BYTES_JS_CONSTRUCTOR, []Node{
+			NewPrimitiveNode(s),
+		}), nil
+	case BYTE_ENCODING_RAW_JSON:
+		if !json.Valid(b) {
+			return nil, fmt.Errorf("value is not valid JSON")
+		}
+		return NewRawJSONNode(b), nil
+	}
+	return nil, fmt.Errorf("unknown byte encoding %d", encoding)
+}
 
-		nodes = append(nodes, node)
+// BytesTelepathAdapter packs byte slices, like []byte and json.RawMessage.
+type BytesTelepathAdapter struct {
+	Encoding ByteEncoding
+}
+
+// BytesAdapter returns an adapter which packs bytes as a base64 encoded string, like encoding/json does.
+func BytesAdapter() *BytesTelepathAdapter {
+	return &BytesTelepathAdapter{
+		Encoding: BYTE_ENCODING_BASE64,
 	}
+}
 
-	return NewListNode(nodes), nil
+// RawJSONAdapter returns an adapter which emits the bytes as-is, the bytes must be valid JSON.
+func RawJSONAdapter() *BytesTelepathAdapter {
+	return &BytesTelepathAdapter{
+		Encoding: BYTE_ENCODING_RAW_JSON,
+	}
+}
+
+func (m *BytesTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
+	var rVal = reflect.ValueOf(value)
+
+	if !rVal.IsValid() || rVal.Kind() == reflect.Slice && rVal.IsNil() {
+		return NullNode(), nil
+	}
+
+	if rVal.Kind() != reflect.Slice || rVal.Type().Elem().Kind() != reflect.Uint8 {
+		return nil, fmt.Errorf("value is not a byte slice: %T", value)
+	}
+
+	return newBytesNode(ctx, c, rVal.Bytes(), m.Encoding)
+}
+
+// ArrayTelepathAdapter packs fixed-size arrays as lists.
+//
+// Arrays of bytes can be packed as a string instead by setting ByteEncoding.
+type ArrayTelepathAdapter struct {
+	ByteEncoding ByteEncoding
+}
+
+func ArrayAdapter() *ArrayTelepathAdapter {
+	return &ArrayTelepathAdapter{}
+}
 
+func (m *ArrayTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
+	var rVal = reflect.ValueOf(value)
+
+	if !rVal.IsValid() {
+		return NullNode(), nil
+	}
+
+	if rVal.Kind() != reflect.Array {
+		return nil, fmt.Errorf("value is not an array: %v", rVal.Kind())
+	}
+
+	if m.ByteEncoding != BYTE_ENCODING_LIST && rVal.Type().Elem().Kind() == reflect.Uint8 {
+		var b = make([]byte, rVal.Len())
+		for i := range b {
+			b[i] = byte(rVal.Index(i).Uint())
+		}
+		return newBytesNode(ctx, c, b, m.ByteEncoding)
+	}
+
+	var nodes, err = buildSliceNodes(ctx, rVal, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewListNode(nodes), nil
 }
 
 type MapTelepathAdapter struct{}
@@ -81,10 +215,7 @@ func MapAdapter() *MapTelepathAdapter {
 }
 
 func (m *MapTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
-	var (
-		rVal  = reflect.ValueOf(value)
-		nodes = make(map[string]Node)
-	)
+	var rVal = reflect.ValueOf(value)
 
 	if !rVal.IsValid() {
 		return NullNode(), nil
@@ -95,20 +226,44 @@ func (m *MapTelepathAdapter) BuildNode(ctx context.Context, value any, c Context
 		return nil, fmt.Errorf("value is not a map: %v", rTyp.Kind())
 	}
 
-	for _, key := range rVal.MapKeys() {
-		var (
-			item      = rVal.MapIndex(key).Interface()
-			node, err = c.BuildNode(ctx, item)
-		)
+	var nodes, err = buildMapNodes(ctx, rVal, c)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	return NewDictNode(nodes), nil
+}
 
-		nodes[key.String()] = node
+func buildSliceNodes(ctx context.Context, rVal reflect.Value, c Context) ([]Node, error) {
+	return buildNodes(ctx, c, rVal.Len(), func(i int) interface{} {
+		return rVal.Index(i).Interface()
+	}, func(i int) interface{} {
+		return i
+	})
+}
+
+// buildMapNodes builds the items of a map in the order of their keys,
+// this keeps the assigned IDs the same between calls.
+func buildMapNodes(ctx context.Context, rVal reflect.Value, c Context) (map[string]Node, error) {
+	var keys = rVal.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	var nodes, err = buildNodes(ctx, c, len(keys), func(i int) interface{} {
+		return rVal.MapIndex(keys[i]).Interface()
+	}, func(i int) interface{} {
+		return keys[i].String()
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return NewDictNode(nodes), nil
+	var dict = make(map[string]Node, len(keys))
+	for i, key := range keys {
+		dict[key.String()] = nodes[i]
+	}
+	return dict, nil
 }
 
 type ErrorTelepathAdapter struct{}
@@ -118,7 +273,15 @@ func ErrorAdapter() *ErrorTelepathAdapter {
 }
 
 func (m *ErrorTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
-	return NewErrorNode(value.(error)), nil
+	var err = value.(error)
+
+	// Keep the structure of errors which contain a validation error.
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return ValidationErrorAdapter().BuildNode(ctx, err, c)
+	}
+
+	return NewErrorNode(err), nil
 }
 
 type AutoTelepathAdapter struct{}
@@ -136,33 +299,16 @@ func (m *AutoTelepathAdapter) BuildNode(ctx context.Context, value any, c Contex
 	switch rTyp.Kind() {
 	case reflect.String:
 		return NewStringNode(value), nil
-	case reflect.Slice:
-		var nodes = make([]Node, 0, rVal.Len())
-		for i := 0; i < rVal.Len(); i++ {
-			var (
-				item      = rVal.Index(i).Interface()
-				node, err = c.BuildNode(ctx, item)
-			)
-			if err != nil {
-				return nil, err
-			}
-
-			nodes = append(nodes, node)
+	case reflect.Slice, reflect.Array:
+		var nodes, err = buildSliceNodes(ctx, rVal, c)
+		if err != nil {
+			return nil, err
 		}
 		return NewListNode(nodes), nil
 	case reflect.Map:
-		var nodes = make(map[string]Node)
-		for _, key := range reflect.ValueOf(value).MapKeys() {
-			var (
-				item      = reflect.ValueOf(value).MapIndex(key).Interface()
-				node, err = c.BuildNode(ctx, item)
-			)
-
-			if err != nil {
-				return nil, err
-			}
-
-			nodes[key.String()] = node
+		var nodes, err = buildMapNodes(ctx, rVal, c)
+		if err != nil {
+			return nil, err
 		}
 		return NewDictNode(nodes), nil
 	default:
@@ -173,6 +319,17 @@ func (m *AutoTelepathAdapter) BuildNode(ctx context.Context, value any, c Contex
 type ObjectAdapter[T any] struct {
 	JSConstructor string
 	GetJSArgs     func(obj T) []interface{}
+
+	// GetJSKwargs is used instead of GetJSArgs if set,
+	// the dict is passed to the JS constructor as its only argument.
+	GetJSKwargs func(obj T) map[string]interface{}
+
+	// Media is added to the context whenever a value is packed.
+	Media Media
+
+	// Args optionally describes the arguments returned by GetJSArgs or the keys returned by GetJSKwargs,
+	// they are validated when packing with JSContext.DevMode enabled.
+	Args []JSArg
 }
 
 func NewTelepathAdapter[T any]() *ObjectAdapter[T] {
@@ -180,17 +337,29 @@ func NewTelepathAdapter[T any]() *ObjectAdapter[T] {
 }
 
 func (m *ObjectAdapter[T]) GetMedia(obj interface{}) Media {
+	if m.Media != nil {
+		return m.Media
+	}
 	return &nullMedia{}
 }
 
 func (m *ObjectAdapter[T]) JSArgs(obj T) []interface{} {
-	if m.GetJSArgs != nil {
+	if m.GetJSKwargs != nil {
+		return []interface{}{m.GetJSKwargs(obj)}
+	} else if m.GetJSArgs != nil {
 		return m.GetJSArgs(obj)
 	} else {
 		return make([]interface{}, 0)
 	}
 }
 
+func (m *ObjectAdapter[T]) Constructor() (string, []JSArg) {
+	if m.GetJSKwargs != nil && m.Args != nil {
+		return m.JSConstructor, []JSArg{{Name: "kwargs", Fields: m.Args}}
+	}
+	return m.JSConstructor, m.Args
+}
+
 func (m *ObjectAdapter[T]) Pack(obj T, context Context) (string, []interface{}) {
 
 	context.AddMedia(
@@ -208,12 +377,20 @@ func (m *ObjectAdapter[T]) BuildNode(ctx context.Context, value any, c Context)
 	if vt, ok = value.(T); !ok {
 		return nil, fmt.Errorf("value is not of type %T", vt)
 	}
+
 	var constructor, args = m.Pack(vt, c)
+	if m.Args != nil && devMode(c) {
+		var _, schema = m.Constructor()
+		if err := ValidateArgs(constructor, schema, args); err != nil {
+			return nil, wrapPathError(err, "_args")
+		}
+	}
+
 	var newArgs = make([]Node, 0, len(args))
-	for _, arg := range args {
+	for i, arg := range args {
 		var node, err = c.BuildNode(ctx, arg)
 		if err != nil {
-			panic(err)
+			return nil, wrapPathError(wrapPathError(err, i), "_args")
 		}
 		newArgs = append(newArgs, node)
 	}
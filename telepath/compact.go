@@ -0,0 +1,252 @@
+package telepath
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	FORMAT_VERSION_VERBOSE = 1 // _type, _args, ... on every object, the default
+	FORMAT_VERSION_COMPACT = 2 // short keys and a table of constructor names
+)
+
+// COMPACT_KEYS maps the reserved keys of the verbose format to their compact alias.
+var COMPACT_KEYS = map[string]string{
+	"_args": "_a",
+	"_dict": "_d",
+	"_id":   "_i",
+	"_list": "_l",
+	"_ref":  "_r",
+	"_type": "_t",
+	"_val":  "_v",
+}
+
+// The compact format is wrapped in an envelope, the unpackers
+// recognise it by the _format key of the root value:
+//
+//	{"_format": 2, "_types": ["js.funcs.Album"], "_value": {"_t": 0, "_a": [...]}}
+//
+// _t is the index of the constructor name in _types.
+const (
+	FORMAT_KEY = "_format"
+	TYPES_KEY  = "_types"
+	VALUE_KEY  = "_value"
+)
+
+// formatEnvelope packs the emitted value in the wire format of the context.
+func formatEnvelope(format int, value interface{}) (interface{}, error) {
+	switch format {
+	case 0, FORMAT_VERSION_VERBOSE:
+		return value, nil
+	case FORMAT_VERSION_COMPACT:
+		var c = &compactor{types: []string{}, index: make(map[string]int)}
+		var compacted = c.compact(value)
+		return map[string]interface{}{
+			FORMAT_KEY: FORMAT_VERSION_COMPACT,
+			TYPES_KEY:  c.types,
+			VALUE_KEY:  compacted,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported format version %d", format)
+}
+
+type compactor struct {
+	types []string
+	index map[string]int
+}
+
+func (c *compactor) typeIndex(name string) int {
+	if i, ok := c.index[name]; ok {
+		return i
+	}
+	var i = len(c.types)
+	c.types = append(c.types, name)
+	c.index[name] = i
+	return i
+}
+
+func (c *compactor) compact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case TelepathValue:
+		return c.compactValue(v)
+	case []interface{}:
+		var list = make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = c.compact(item)
+		}
+		return list
+	case map[string]interface{}:
+		// Dicts are only wrapped for the verbose keys when emitted,
+		// the short keys are reserved in the compact format.
+		if isCompactReserved(v) {
+			return map[string]interface{}{"_d": c.compactDict(v)}
+		}
+		return c.compactDict(v)
+	}
+	return value
+}
+
+func (c *compactor) compactDict(dict map[string]interface{}) map[string]interface{} {
+	var result = make(map[string]interface{}, len(dict))
+
+	// Keys are sorted so the constructor table is in the same order on every pack.
+	var keys = make([]string, 0, len(dict))
+	for key := range dict {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		result[key] = c.compact(dict[key])
+	}
+	return result
+}
+
+// compactValue follows the key rules of TelepathValue.MarshalJSON.
+func (c *compactor) compactValue(v TelepathValue) map[string]interface{} {
+	var result = make(map[string]interface{}, 3)
+
+	if v.Type != "" {
+		result["_t"] = c.typeIndex(v.Type)
+	}
+	if v.Args != nil || v.Type != "" {
+		var args = make([]interface{}, len(v.Args))
+		for i, arg := range v.Args {
+			args[i] = c.compact(arg)
+		}
+		result["_a"] = args
+	}
+	if v.Dict != nil {
+		result["_d"] = c.compactDict(v.Dict)
+	}
+	if v.List != nil {
+		result["_l"] = c.compact(v.List)
+	}
+	if v.Val != nil {
+		result["_v"] = v.Val
+	}
+	if v.Ref != 0 {
+		result["_r"] = v.Ref
+	}
+	if v.ID != 0 {
+		result["_i"] = v.ID
+	}
+
+	return result
+}
+
+// expandEnvelope converts a decoded value in any wire format to the verbose format.
+func expandEnvelope(packed interface{}) (interface{}, error) {
+	var envelope, ok = packed.(map[string]interface{})
+	if !ok {
+		return packed, nil
+	}
+
+	var rawFormat, isEnvelope = envelope[FORMAT_KEY]
+	if !isEnvelope {
+		return packed, nil
+	}
+
+	var format, err = unpackID(rawFormat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", FORMAT_KEY, rawFormat)
+	}
+
+	switch format {
+	case FORMAT_VERSION_VERBOSE:
+		return envelope[VALUE_KEY], nil
+	case FORMAT_VERSION_COMPACT:
+		var rawTypes, _ = envelope[TYPES_KEY].([]interface{})
+		var types = make([]string, len(rawTypes))
+		for i, rawType := range rawTypes {
+			var name, ok = rawType.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s[%d] is not a string: %T", TYPES_KEY, i, rawType)
+			}
+			types[i] = name
+		}
+		return expandCompact(envelope[VALUE_KEY], types)
+	}
+
+	return nil, fmt.Errorf("unsupported format version %d", format)
+}
+
+func isCompactReserved(obj map[string]interface{}) bool {
+	for _, key := range COMPACT_KEYS {
+		if _, ok := obj[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func expandCompact(packed interface{}, types []string) (interface{}, error) {
+	switch v := packed.(type) {
+	case []interface{}:
+		var list = make([]interface{}, len(v))
+		for i, item := range v {
+			var value, err = expandCompact(item, types)
+			if err != nil {
+				return nil, wrapPathError(err, i)
+			}
+			list[i] = value
+		}
+		return list, nil
+	case map[string]interface{}:
+		if !isCompactReserved(v) {
+			return expandCompactDict(v, types)
+		}
+		return expandCompactValue(v, types)
+	}
+	return packed, nil
+}
+
+func expandCompactDict(dict map[string]interface{}, types []string) (map[string]interface{}, error) {
+	var result = make(map[string]interface{}, len(dict))
+	for key, item := range dict {
+		var value, err = expandCompact(item, types)
+		if err != nil {
+			return nil, wrapPathError(err, key)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func expandCompactValue(obj map[string]interface{}, types []string) (map[string]interface{}, error) {
+	var result = make(map[string]interface{}, len(obj))
+
+	for verbose, short := range COMPACT_KEYS {
+		var item, ok = obj[short]
+		if !ok {
+			continue
+		}
+
+		var err error
+		switch verbose {
+		case "_type":
+			var i int
+			if i, err = unpackID(item); err != nil || i < 0 || i >= len(types) {
+				return nil, fmt.Errorf("invalid constructor index: %v", item)
+			}
+			result[verbose] = types[i]
+		case "_args", "_list":
+			result[verbose], err = expandCompact(item, types)
+		case "_dict":
+			var dict, isDict = item.(map[string]interface{})
+			if !isDict {
+				return nil, fmt.Errorf("%s is not a dict: %T", short, item)
+			}
+			result[verbose], err = expandCompactDict(dict, types)
+		default:
+			// _val is never unpacked, _id and _ref are numbers
+			result[verbose] = item
+		}
+
+		if err != nil {
+			return nil, wrapPathError(err, short)
+		}
+	}
+
+	return result, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sync"
 )
 
 var _ Context = (*ValueContext)(nil)
@@ -11,6 +12,38 @@ var _ Context = (*ValueContext)(nil)
 type JSContext struct {
 	Media           Media
 	AdapterRegistry *AdapterRegistry
+
+	// Parallelism is the maximum amount of goroutines used to build the items
+	// of large slices and maps. Zero or one means items are built sequentially.
+	//
+	// Adapters (and their GetJSArgs functions) must be safe for concurrent use
+	// when this is enabled.
+	Parallelism int
+
+	// ParallelMinItems is the minimum amount of items a slice or map
+	// must have to be built in parallel, defaults to PARALLEL_MIN_ITEMS.
+	ParallelMinItems int
+
+	// Loaders are the batch loaders which values returned by Load() are loaded with.
+	Loaders map[string]BatchLoader
+
+	// FloatPolicy decides how NaN and infinite floats are packed.
+	FloatPolicy FloatPolicy
+
+	// BigIntPolicy decides how integers larger than MAX_SAFE_INTEGER are packed.
+	BigIntPolicy BigIntPolicy
+
+	// MaxIterItems is the maximum amount of items drained from
+	// an iterator or channel, defaults to ITER_MAX_ITEMS.
+	MaxIterItems int
+
+	// DevMode enables checks which are too expensive for production,
+	// such as validating arguments against ObjectAdapter.Args.
+	DevMode bool
+
+	// Format is the wire format version values are packed in,
+	// FORMAT_VERSION_COMPACT opts in to the compact format.
+	Format int
 }
 
 func (c *JSContext) AddMedia(media Media) {
@@ -22,12 +55,14 @@ func (c *JSContext) Registry() *AdapterRegistry {
 }
 
 func (c *JSContext) Pack(ctx context.Context, value interface{}) (interface{}, error) {
-	var newCtx = NewValueContext(c)
-	var v, err = newCtx.BuildNode(ctx, value)
+	var newCtx = AcquireValueContext(c)
+	defer newCtx.Release()
+
+	var v, err = newCtx.BuildRoot(ctx, value)
 	if err != nil {
 		return nil, err
 	}
-	return v.Emit(), nil
+	return formatEnvelope(c.Format, v.Emit())
 }
 
 type ValueContext struct {
@@ -36,18 +71,36 @@ type ValueContext struct {
 	Nodes           map[uintptr]Node
 	RawValues       map[uintptr]interface{} // keep reference to prevent GC
 	NextID          int
+	NodeCount       int // amount of nodes built, used to periodically check for cancellation
+
+	mu          sync.Mutex
+	workers     chan struct{}              // nil if nodes are built sequentially
+	lazyResults map[*LazyValue]*lazyResult // resolved lazy values, allocated on first use
+	pending     []*DeferredNode            // nodes waiting for their batch loader
+	primitives  primitiveSlab              // storage for primitive nodes, reused after Release()
 }
 
 func NewValueContext(c *JSContext) *ValueContext {
-	return &ValueContext{
-		ParentContext:   c,
-		AdapterRegistry: c.Registry(),
-		Nodes:           make(map[uintptr]Node),
-		RawValues:       make(map[uintptr]interface{}),
+	var v = &ValueContext{
+		Nodes:     make(map[uintptr]Node),
+		RawValues: make(map[uintptr]interface{}),
+	}
+	v.init(c)
+	return v
+}
+
+func (c *ValueContext) init(parent *JSContext) {
+	c.ParentContext = parent
+	c.AdapterRegistry = parent.Registry()
+	if parent.Parallelism > 1 {
+		// The goroutine which is building the parent node also does work.
+		c.workers = make(chan struct{}, parent.Parallelism-1)
 	}
 }
 
 func (c *ValueContext) AddMedia(media Media) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.ParentContext.AddMedia(media)
 }
 
@@ -57,35 +110,96 @@ func (c *ValueContext) Registry() *AdapterRegistry {
 
 func (c *ValueContext) buildNewNode(ctx context.Context, value interface{}) (Node, error) {
 
+	var v = reflect.ValueOf(value)
+
+	// Nil pointers are always packed as null, like encoding/json does.
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return NullNode(), nil
+	}
+
+	// Adapters might be expensive, don't call them if the request is already gone.
+	if err := ctx.Err(); err != nil {
+		return nil, &PathError{Err: err}
+	}
+
 	var adapter, ok = c.AdapterRegistry.Find(ctx, value)
 	if ok {
 		return adapter.BuildNode(ctx, value, c)
 	}
 
-	var v = reflect.ValueOf(value)
-
 	if !v.IsValid() {
 		return NullNode(), nil
 	}
 
 	switch v.Type().Kind() {
+	case reflect.Ptr:
+		// No adapter for the pointer type, build the value it points to.
+		// Pointers to pointers are built through BuildNode so they are
+		// deduplicated by their own address too.
+		var elem = v.Elem()
+		if elem.Kind() == reflect.Ptr {
+			return c.BuildNode(ctx, elem.Interface())
+		}
+		return c.buildNewNode(ctx, elem.Interface())
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
 		reflect.Float32, reflect.Float64, reflect.Bool, reflect.Invalid:
 		return BaseAdapter().BuildNode(ctx, value, c)
-	case reflect.Slice, reflect.Array:
+	case reflect.Slice:
 		var n, err = SliceAdapter().BuildNode(ctx, value, c)
 		return n, err
+	case reflect.Array:
+		return ArrayAdapter().BuildNode(ctx, value, c)
 	case reflect.Map:
 		return MapAdapter().BuildNode(ctx, value, c)
 	case reflect.String:
 		return StringAdapter().BuildNode(ctx, value, c)
+	case reflect.Func:
+		if _, ok := seqYieldType(v.Type()); ok {
+			return IterAdapter().BuildNode(ctx, value, c)
+		}
+	case reflect.Chan:
+		return ChanAdapter().BuildNode(ctx, value, c)
 	}
 
 	return nil, fmt.Errorf("no adapter found for value %v (%T)", value, value)
 }
 
+// BuildRoot builds the node for the value which is being packed.
+//
+// Values returned by Load() are loaded and built after the rest of the tree.
+//
+// When building in parallel, IDs are assigned after the whole tree has been built,
+// this makes sure the output does not depend on the order in which goroutines finish.
+func (c *ValueContext) BuildRoot(ctx context.Context, value interface{}) (Node, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &PathError{Err: err}
+	}
+
+	var node, err = c.BuildNode(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.resolvePending(ctx, node); err != nil {
+		return nil, err
+	}
+	if c.workers != nil {
+		c.assignIDs(node)
+	}
+	return node, nil
+}
+
 func (c *ValueContext) BuildNode(ctx context.Context, value interface{}) (Node, error) {
+	switch value.(type) {
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, string:
+		if node, ok := c.buildPrimitive(ctx, value); ok {
+			if err := c.countNodes(ctx, 1); err != nil {
+				return nil, err
+			}
+			return node, nil
+		}
+	}
+
 	var (
 		rVal   = reflect.ValueOf(value)
 		node   Node
@@ -94,26 +208,112 @@ func (c *ValueContext) BuildNode(ctx context.Context, value interface{}) (Node,
 	)
 
 	switch rVal.Kind() {
-	case reflect.Ptr, reflect.Slice, reflect.Map:
+	case reflect.Ptr, reflect.Map:
 		objKey = rVal.Pointer()
+	case reflect.Slice:
+		// Empty slices may all point to the same address.
+		if rVal.Len() > 0 {
+			objKey = rVal.Pointer()
+		}
 	}
 
-	if node, ok = c.Nodes[objKey]; !ok {
-		node, err := c.buildNewNode(ctx, value)
-		if err != nil {
-			return nil, err
-		}
-		if objKey != 0 {
-			c.Nodes[objKey] = node
-			c.RawValues[objKey] = value
-		}
+	c.mu.Lock()
+	c.NodeCount++
+
+	if node, ok = c.Nodes[objKey]; ok && sameValue(c.RawValues[objKey], rVal) {
+		c.reference(node)
+		c.mu.Unlock()
 		return node, nil
+	} else if ok {
+		// A different value at the same address, like a struct and its first field.
+		objKey = 0
 	}
+	c.mu.Unlock()
 
-	if node.GetID() == 0 {
-		c.NextID++
-		node.SetID(c.NextID)
+	node, err := c.buildNewNode(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if objKey == 0 {
+		return node, nil
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another goroutine might have built the same value in the meantime.
+	if existing, ok := c.Nodes[objKey]; ok {
+		if !sameValue(c.RawValues[objKey], rVal) {
+			return node, nil
+		}
+		c.reference(existing)
+		return existing, nil
+	}
+
+	c.Nodes[objKey] = node
+	c.RawValues[objKey] = value
 	return node, nil
 }
+
+// countNodes adds nodes which were built without an adapter call, like primitive values
+// and the items of primitive slices, to NodeCount.
+//
+// Checking the context for each of these would take longer than building them,
+// it is checked whenever the count passes a multiple of CONTEXT_CHECK_INTERVAL.
+func (c *ValueContext) countNodes(ctx context.Context, n int) error {
+	if c.workers != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+
+	var before = c.NodeCount
+	c.NodeCount += n
+	if before/CONTEXT_CHECK_INTERVAL == c.NodeCount/CONTEXT_CHECK_INTERVAL {
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return &PathError{Err: err}
+	}
+	return nil
+}
+
+// countNodes counts the nodes if the context is a *ValueContext,
+// other contexts are checked for cancellation directly.
+func countNodes(ctx context.Context, c Context, n int) error {
+	if valueCtx, ok := c.(*ValueContext); ok {
+		return valueCtx.countNodes(ctx, n)
+	}
+	if err := ctx.Err(); err != nil {
+		return &PathError{Err: err}
+	}
+	return nil
+}
+
+// sameValue reports whether the value stored for an address is the value being built,
+// values of different types or slices of different lengths can share an address.
+func sameValue(raw interface{}, rVal reflect.Value) bool {
+	var rawVal = reflect.ValueOf(raw)
+	if rawVal.Type() != rVal.Type() {
+		return false
+	}
+	return rVal.Kind() != reflect.Slice || rawVal.Len() == rVal.Len()
+}
+
+// reference is called when an already built node is used again.
+// c.mu must be held by the caller.
+func (c *ValueContext) reference(node Node) {
+	if c.workers != nil {
+		// IDs are assigned by BuildRoot
+		return
+	}
+
+	if node.GetID() == 0 {
+		// Nodes which are never referenced ignore the ID.
+		node.SetID(c.NextID + 1)
+		if node.GetID() != 0 {
+			c.NextID++
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package telepath
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encoder encodes the tree returned by JSContext.Pack.
+type Encoder interface {
+	ContentType() string
+	Encode(value interface{}) ([]byte, error)
+}
+
+// Decoder decodes encoded values into lists, string keyed dicts and primitives for the Unpacker.
+type Decoder interface {
+	Decode(data []byte) (interface{}, error)
+}
+
+var (
+	JSONEncoding    = &jsonEncoding{}
+	CBOREncoding    = newCBOREncoding()
+	MsgpackEncoding = &msgpackEncoding{}
+)
+
+type jsonEncoding struct{}
+
+func (e *jsonEncoding) ContentType() string {
+	return "application/json"
+}
+
+func (e *jsonEncoding) Encode(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (e *jsonEncoding) Decode(data []byte) (interface{}, error) {
+	var value interface{}
+	var err = json.Unmarshal(data, &value)
+	return value, err
+}
+
+type cborEncoding struct {
+	enc cbor.EncMode
+	dec cbor.DecMode
+}
+
+func newCBOREncoding() *cborEncoding {
+	var enc, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic(err)
+	}
+
+	dec, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+
+	return &cborEncoding{enc: enc, dec: dec}
+}
+
+func (e *cborEncoding) ContentType() string {
+	return "application/cbor"
+}
+
+func (e *cborEncoding) Encode(value interface{}) ([]byte, error) {
+	var wire, err = wireValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return e.enc.Marshal(wire)
+}
+
+func (e *cborEncoding) Decode(data []byte) (interface{}, error) {
+	var value interface{}
+	var err = e.dec.Unmarshal(data, &value)
+	return value, err
+}
+
+type msgpackEncoding struct{}
+
+func (e *msgpackEncoding) ContentType() string {
+	return "application/msgpack"
+}
+
+func (e *msgpackEncoding) Encode(value interface{}) ([]byte, error) {
+	var wire, err = wireValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	var enc = msgpack.NewEncoder(&b)
+	enc.SetSortMapKeys(true)
+	if err = enc.Encode(wire); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (e *msgpackEncoding) Decode(data []byte) (interface{}, error) {
+	var value interface{}
+	var err = msgpack.Unmarshal(data, &value)
+	return value, err
+}
+
+// wireValue converts the emitted tree into plain lists and dicts for encoders
+// which do not use TelepathValue.MarshalJSON, raw JSON values and marshalers are
+// converted the way encoding/json would encode them.
+func wireValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case TelepathValue:
+		return v.wireValue()
+	case []interface{}:
+		var list = make([]interface{}, len(v))
+		for i, item := range v {
+			var wire, err = wireValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = wire
+		}
+		return list, nil
+	case map[string]interface{}:
+		var dict = make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var wire, err = wireValue(item)
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = wire
+		}
+		return dict, nil
+	case json.RawMessage:
+		var decoded interface{}
+		if err := json.Unmarshal(v, &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	case json.Marshaler:
+		// Marshalers are encoded as their JSON, like encoding/json does.
+		var data, err = v.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return wireValue(json.RawMessage(data))
+	case encoding.TextMarshaler:
+		var text, err = v.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return string(text), nil
+	}
+
+	// Nodes may emit typed slices and maps, their items might need converting too.
+	var rv = reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 || rv.Kind() == reflect.Slice && rv.IsNil() {
+			return value, nil
+		}
+		var list = make([]interface{}, rv.Len())
+		for i := range list {
+			var wire, err = wireValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			list[i] = wire
+		}
+		return list, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || rv.IsNil() {
+			return value, nil
+		}
+		var dict = make(map[string]interface{}, rv.Len())
+		var iter = rv.MapRange()
+		for iter.Next() {
+			var wire, err = wireValue(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			dict[iter.Key().String()] = wire
+		}
+		return dict, nil
+	}
+
+	return value, nil
+}
+
+// wireValue returns the keys which MarshalJSON would encode.
+func (v TelepathValue) wireValue() (map[string]interface{}, error) {
+	var (
+		result = make(map[string]interface{}, 3)
+		err    error
+	)
+
+	if v.Type != "" {
+		result["_type"] = v.Type
+	}
+	if v.Args != nil || v.Type != "" {
+		var args = v.Args
+		if args == nil {
+			args = []any{}
+		}
+		if result["_args"], err = wireValue(args); err != nil {
+			return nil, err
+		}
+	}
+	if v.Dict != nil {
+		if result["_dict"], err = wireValue(v.Dict); err != nil {
+			return nil, err
+		}
+	}
+	if v.List != nil {
+		if result["_list"], err = wireValue(v.List); err != nil {
+			return nil, err
+		}
+	}
+	if v.Val != nil {
+		if result["_val"], err = wireValue(v.Val); err != nil {
+			return nil, err
+		}
+	}
+	if v.Ref != 0 {
+		result["_ref"] = v.Ref
+	}
+	if v.ID != 0 {
+		result["_id"] = v.ID
+	}
+
+	return result, nil
+}
@@ -0,0 +1,145 @@
+package telepath
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnumMode decides how an EnumTelepathAdapter packs its values.
+type EnumMode int
+
+const (
+	ENUM_MODE_NAME     EnumMode = iota // Pack the name of the value as a string
+	ENUM_MODE_CONSTANT                 // Pack as {_type: JSConstructor, _args: [name]}
+	ENUM_MODE_VALUE                    // Pack the underlying value, like BaseTelepathAdapter would
+)
+
+// EnumTelepathAdapter packs named constants using a value to name table.
+//
+// Values missing from Names fail to pack unless Mode is ENUM_MODE_VALUE.
+type EnumTelepathAdapter[T comparable] struct {
+	JSConstructor string
+	Names         map[T]string
+	Mode          EnumMode
+}
+
+// EnumAdapter returns an adapter which packs values as their name.
+func EnumAdapter[T comparable](jsConstructor string, names map[T]string) *EnumTelepathAdapter[T] {
+	return &EnumTelepathAdapter[T]{
+		JSConstructor: jsConstructor,
+		Names:         names,
+		Mode:          ENUM_MODE_NAME,
+	}
+}
+
+// StringerEnumAdapter builds the table of an EnumAdapter from the String() method of values.
+func StringerEnumAdapter[T interface {
+	comparable
+	fmt.Stringer
+}](jsConstructor string, values ...T) *EnumTelepathAdapter[T] {
+	var names = make(map[T]string, len(values))
+	for _, v := range values {
+		names[v] = v.String()
+	}
+	return EnumAdapter(jsConstructor, names)
+}
+
+// Constants returns the table as name to underlying value,
+// so the JS side can register matching constants.
+func (m *EnumTelepathAdapter[T]) Constants() map[string]interface{} {
+	var constants = make(map[string]interface{}, len(m.Names))
+	for v, name := range m.Names {
+		constants[name] = enumValue(v)
+	}
+	return constants
+}
+
+func (m *EnumTelepathAdapter[T]) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
+	var v, ok = value.(T)
+	if !ok {
+		return nil, fmt.Errorf("value is not of type %T", v)
+	}
+
+	if m.Mode == ENUM_MODE_VALUE {
+		// Building v itself would end up right back in this adapter.
+		return BaseAdapter().BuildNode(ctx, enumValue(v), c)
+	}
+
+	name, ok := m.Names[v]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s value: %v", m.JSConstructor, enumValue(v))
+	}
+
+	var node, err = c.BuildNode(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Mode == ENUM_MODE_CONSTANT {
+		return NewObjectNode(m.JSConstructor, []Node{node}), nil
+	}
+
+	return node, nil
+}
+
+var _ ConstructorAdapter = (*EnumTelepathAdapter[int])(nil)
+
+// Constructor returns the JS constructor and its name argument in ENUM_MODE_CONSTANT,
+// values are not packed as a constructor call in the other modes.
+func (m *EnumTelepathAdapter[T]) Constructor() (string, []JSArg) {
+	if m.Mode != ENUM_MODE_CONSTANT {
+		return "", nil
+	}
+	return m.JSConstructor, []JSArg{Arg[string]("name")}
+}
+
+// TypeScriptType returns the union of the names, the constructor's object type
+// or the underlying type, depending on Mode.
+func (m *EnumTelepathAdapter[T]) TypeScriptType() string {
+	switch m.Mode {
+	case ENUM_MODE_CONSTANT:
+		return fmt.Sprintf("TelepathObject<%s>", strconv.Quote(m.JSConstructor))
+	case ENUM_MODE_VALUE:
+		switch reflect.TypeOf((*T)(nil)).Elem().Kind() {
+		case reflect.String:
+			return "string"
+		case reflect.Bool:
+			return "boolean"
+		}
+		return "number"
+	}
+
+	if len(m.Names) == 0 {
+		return "never"
+	}
+
+	var names = make([]string, 0, len(m.Names))
+	for _, name := range m.Names {
+		names = append(names, strconv.Quote(name))
+	}
+	sort.Strings(names)
+	return strings.Join(slices.Compact(names), " | ")
+}
+
+// enumValue converts named numbers and strings to their underlying type.
+func enumValue(value interface{}) interface{} {
+	var rVal = reflect.ValueOf(value)
+	switch rVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rVal.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rVal.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rVal.Float()
+	case reflect.String:
+		return rVal.String()
+	case reflect.Bool:
+		return rVal.Bool()
+	}
+	return value
+}
@@ -1,5 +1,10 @@
 package telepath
 
+import (
+	"fmt"
+	"strings"
+)
+
 type UnpackableError struct {
 	Err error
 	Obj Node
@@ -8,3 +13,60 @@ type UnpackableError struct {
 func (e *UnpackableError) Error() string {
 	return e.Err.Error()
 }
+
+// PathError is returned when packing fails somewhere inside of a value.
+//
+// Path holds the list indices (int) and dict keys / argument names (string)
+// leading from the packed root to the value which failed.
+type PathError struct {
+	Path []interface{}
+	Err  error
+}
+
+func (e *PathError) PathString() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, seg := range e.Path {
+		switch s := seg.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", s)
+		default:
+			fmt.Fprintf(&b, ".%v", s)
+		}
+	}
+	return b.String()
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s: %v", e.PathString(), e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// wrapPathError prepends seg to the path of err.
+//
+// It is called while unwinding out of a failed child node,
+// the happy path does not have to keep track of the path at all.
+func wrapPathError(err error, seg interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	var pathErr, ok = err.(*PathError)
+	if !ok {
+		return &PathError{
+			Path: []interface{}{seg},
+			Err:  err,
+		}
+	}
+
+	var path = make([]interface{}, 0, len(pathErr.Path)+1)
+	path = append(path, seg)
+	path = append(path, pathErr.Path...)
+	return &PathError{
+		Path: path,
+		Err:  pathErr.Err,
+	}
+}
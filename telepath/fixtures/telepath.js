@@ -1,3 +1,3 @@
-!function(t,e){"object"==typeof exports&&"object"==typeof module?module.exports=e():"function"==typeof define&&define.amd?define([],e):"object"==typeof exports?exports.Telepath=e():t.Telepath=e()}(this,(()=>(()=>{"use strict";var t={d:(e,i)=>{for(var n in i)t.o(i,n)&&!t.o(e,n)&&Object.defineProperty(e,n,{enumerable:!0,get:i[n]})},o:(t,e)=>Object.prototype.hasOwnProperty.call(t,e)},e={};t.d(e,{default:()=>i});const i=class{constructor(){this.constructors={}}register(t,e){this.constructors[t]=e}unpack(t){const e={};return this.scanForIds(t,e),this.unpackWithRefs(t,e,{})}scanForIds(t,e){if(null===t||"object"!=typeof t)return;if(Array.isArray(t))return void t.forEach((t=>this.scanForIds(t,e)));let i=!1;if("_id"in t&&(i=!0,e[t._id]=t),("_type"in t||"_val"in t||"_ref"in t)&&(i=!0),"_list"in t&&(i=!0,t._list.forEach(function(t){this.scanForIds(t,e)}.bind(this))),"_args"in t&&(i=!0,t._args.forEach(function(t){this.scanForIds(t,e)}.bind(this))),"_dict"in t){i=!0;for(const[i,n]of Object.entries(t._dict))this.scanForIds(n,e)}if(!i)for(const[i,n]of Object.entries(t))this.scanForIds(n,e)}unpackWithRefs(t,e,i){if(null===t||"object"!=typeof t)return t;if(Array.isArray(t))return t.map((t=>this.unpackWithRefs(t,e,i)));let n;if("_ref"in t)n=t._ref in i?i[t._ref]:this.unpackWithRefs(e[t._ref],e,i);else if("_val"in t)n=t._val;else if("_list"in t)n=t._list.map(function(t){return this.unpackWithRefs(t,e,i)}.bind(this));else if("_dict"in t){n={};for(const[r,s]of Object.entries(t._dict))n[r]=this.unpackWithRefs(s,e,i)}else{if(!("_type"in t)){if("_id"in t)throw new Error("telepath encountered object with _id but no type specified");n={};for(const[r,s]of Object.entries(t))n[r]=this.unpackWithRefs(s,e,i);return n}{const r=t._type;if(!(r in this.constructors))throw new Error("telepath unpack found unknown constructor id: "+r);n=new(0,this.constructors[r])(...t._args.map(function(t){return this.unpackWithRefs(t,e,i)}.bind(this)))}}return"_id"in t&&(i[t._id]=n),n}};return e.default})()));
+!function(t,e){"object"==typeof exports&&"object"==typeof module?module.exports=e():"function"==typeof define&&define.amd?define([],e):"object"==typeof exports?exports.Telepath=e():t.Telepath=e()}(this,(()=>{var __telepath=(()=>{var u=Object.defineProperty;var p=Object.getOwnPropertyDescriptor;var d=Object.getOwnPropertyNames;var m=Object.prototype.hasOwnProperty;var E=(o,r)=>{for(var t in r)u(o,t,{get:r[t],enumerable:!0})},k=(o,r,t,e)=>{if(r&&typeof r=="object"||typeof r=="function")for(let n of d(r))!m.call(o,n)&&n!==t&&u(o,n,{get:()=>r[n],enumerable:!(e=p(r,n))||e.enumerable});return o};var a=o=>k(u({},"__esModule",{value:!0}),o);var w={};E(w,{default:()=>x});var A="ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/";function F(o){let r=o.replace(/=+$/,""),t=new Uint8Array(Math.floor(r.length*3/4)),e=0,n=0,i=0;for(let s=0;s<r.length;s++)e=e<<6|A.indexOf(r[s]),n+=6,n>=8&&(n-=8,t[i++]=e>>n&255);return t}var O=1,R=2,l={_args:"_a",_dict:"_d",_id:"_i",_list:"_l",_ref:"_r",_type:"_t",_val:"_v"},h=class{constructor(r,t,e){this.errors=r||[],this.fieldErrors=t||{},this.blockErrors=e||{}}get messages(){return this.errors.map(r=>r.message)}toString(){return this.messages.join(" ")}},f=class{constructor(){this.constructors={},this.factories={},this.registerFactory("telepath.Uint8Array",F),this.registerFactory("telepath.Float",r=>Number(r)),this.registerFactory("telepath.BigInt",r=>typeof BigInt=="function"?BigInt(r):r),this.register("telepath.ValidationError",h)}register(r,t){this.constructors[r]=t}registerFactory(r,t){this.factories[r]=t}unpack(r){r=this.expandFormat(r);let t={};this.scanForIds(r,t);let e={};return this.unpackWithRefs(r,t,e)}expandFormat(r){if(r===null||typeof r!="object"||Array.isArray(r)||!("_format"in r))return r;switch(r._format){case O:return r._value;case R:return this.expandCompact(r._value,r._types||[]);default:throw new Error("telepath unpack found unsupported format version: "+r._format)}}expandCompact(r,t){if(r===null||typeof r!="object")return r;if(Array.isArray(r))return r.map(i=>this.expandCompact(i,t));let e=Object.keys(l).some(i=>l[i]in r),n={};if(!e){for(let[i,s]of Object.entries(r))n[i]=this.expandCompact(s,t);return n}for(let[i,s]of Object.entries(l)){if(!(s in r))continue;let c=r[s];if(i==="_type"){if(!(Number.isInteger(c)&&c>=0&&c<t.length))throw new Error("telepath unpack found unknown constructor index: "+c);n[i]=t[c]}else if(i==="_args"||i==="_list")n[i]=this.expandCompact(c,t);else if(i==="_dict"){let _={};for(let[y,g]of Object.entries(c))_[y]=this.expandCompact(g,t);n[i]=_}else n[i]=c}return n}scanForIds(r,t){if(r===null||typeof r!="object")return;if(Array.isArray(r)){r.forEach(n=>this.scanForIds(n,t));return}let e=!1;if("_id"in r&&(e=!0,t[r._id]=r),("_type"in r||"_val"in r||"_ref"in r)&&(e=!0),"_list"in r&&(e=!0,r._list.forEach(function(n){this.scanForIds(n,t)}.bind(this))),"_args"in r&&(e=!0,r._args.forEach(function(n){this.scanForIds(n,t)}.bind(this))),"_dict"in r){e=!0;for(let[n,i]of Object.entries(r._dict))this.scanForIds(i,t)}if(!e)for(let[n,i]of Object.entries(r))this.scanForIds(i,t)}unpackWithRefs(r,t,e){if(r===null||typeof r!="object")return r;if(Array.isArray(r))return r.map(i=>this.unpackWithRefs(i,t,e));let n;if("_ref"in r)r._ref in e?n=e[r._ref]:n=this.unpackWithRefs(t[r._ref],t,e);else if("_val"in r)n=r._val;else if("_list"in r)n=r._list.map(function(i){return this.unpackWithRefs(i,t,e)}.bind(this));else if("_dict"in r){n={};for(let[i,s]of Object.entries(r._dict))n[i]=this.unpackWithRefs(s,t,e)}else if("_type"in r){let i=r._type;if(!(i in this.constructors)&&!(i in this.factories))throw new Error("telepath unpack found unknown constructor id: "+i);let s=r._args.map(function(c){return this.unpackWithRefs(c,t,e)}.bind(this));if(i in this.constructors){let c=this.constructors[i];n=new c(...s)}else n=this.factories[i](...s)}else{if("_id"in r)throw new Error("telepath encountered object with _id but no type specified");n={};for(let[i,s]of Object.entries(r))n[i]=this.unpackWithRefs(s,t,e);return n}return"_id"in r&&(e[r._id]=n),n}};f.ValidationError=h;var x=f;return a(w);})();return __telepath.default}));
 
 const TELEPATH = new Telepath();
\ No newline at end of file
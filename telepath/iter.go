@@ -0,0 +1,182 @@
+package telepath
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+var (
+	rValTrue  = []reflect.Value{reflect.ValueOf(true)}
+	rValFalse = []reflect.Value{reflect.ValueOf(false)}
+)
+
+// iterLimit returns the maximum amount of items which are drained from iterators and channels.
+func iterLimit(c Context) int {
+	if valueCtx, ok := c.(*ValueContext); ok && valueCtx.ParentContext != nil && valueCtx.ParentContext.MaxIterItems > 0 {
+		return valueCtx.ParentContext.MaxIterItems
+	}
+	return ITER_MAX_ITEMS
+}
+
+// seqYieldType returns the type of the yield function if t is shaped like
+// iter.Seq[V] or iter.Seq2[K, V]; func(yield func(V) bool) or func(yield func(K, V) bool).
+func seqYieldType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 0 {
+		return nil, false
+	}
+
+	var yield = t.In(0)
+	if yield.Kind() != reflect.Func || yield.NumOut() != 1 || yield.Out(0).Kind() != reflect.Bool {
+		return nil, false
+	}
+
+	if yield.NumIn() != 1 && yield.NumIn() != 2 {
+		return nil, false
+	}
+
+	return yield, true
+}
+
+// IterTelepathAdapter drains iterators into a list, or into a dict for iterators yielding string keys.
+//
+// Iterators are stopped when the context is cancelled or when they yield more than
+// JSContext.MaxIterItems items, in which case an error is returned.
+type IterTelepathAdapter struct{}
+
+func IterAdapter() *IterTelepathAdapter {
+	return &IterTelepathAdapter{}
+}
+
+func (m *IterTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
+	var rVal = reflect.ValueOf(value)
+	if !rVal.IsValid() || rVal.Kind() == reflect.Func && rVal.IsNil() {
+		return NullNode(), nil
+	}
+
+	var yieldType, ok = seqYieldType(rVal.Type())
+	if !ok {
+		return nil, fmt.Errorf("value is not an iterator: %T", value)
+	}
+
+	var isSeq2 = yieldType.NumIn() == 2
+	if isSeq2 && yieldType.In(0).Kind() != reflect.String {
+		return nil, fmt.Errorf("iterator keys must be strings, got %v", yieldType.In(0))
+	}
+
+	var (
+		limit = iterLimit(c)
+		list  = make([]Node, 0)
+		dict  = make(map[string]Node)
+		count int
+		err   error
+	)
+
+	var yield = reflect.MakeFunc(yieldType, func(args []reflect.Value) []reflect.Value {
+		if err != nil {
+			// The iterator did not stop after yield returned false.
+			return rValFalse
+		}
+
+		if err = ctx.Err(); err != nil {
+			if isSeq2 {
+				err = wrapPathError(err, args[0].String())
+			} else {
+				err = wrapPathError(err, count)
+			}
+			return rValFalse
+		}
+
+		if count >= limit {
+			err = fmt.Errorf("iterator yielded more than %d items", limit)
+			return rValFalse
+		}
+
+		var node Node
+		if isSeq2 {
+			var key = args[0].String()
+			if node, err = c.BuildNode(ctx, args[1].Interface()); err != nil {
+				err = wrapPathError(err, key)
+				return rValFalse
+			}
+			dict[key] = node
+		} else {
+			if node, err = c.BuildNode(ctx, args[0].Interface()); err != nil {
+				err = wrapPathError(err, count)
+				return rValFalse
+			}
+			list = append(list, node)
+		}
+
+		count++
+		return rValTrue
+	})
+
+	rVal.Call([]reflect.Value{yield})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if isSeq2 {
+		return NewDictNode(dict), nil
+	}
+
+	return NewListNode(list), nil
+}
+
+// ChanTelepathAdapter drains channels into a list until they are closed.
+//
+// Receiving is stopped when the context is cancelled or when more than
+// JSContext.MaxIterItems items are received, in which case an error is returned.
+type ChanTelepathAdapter struct{}
+
+func ChanAdapter() *ChanTelepathAdapter {
+	return &ChanTelepathAdapter{}
+}
+
+func (m *ChanTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
+	var rVal = reflect.ValueOf(value)
+	if !rVal.IsValid() || rVal.Kind() == reflect.Chan && rVal.IsNil() {
+		return NullNode(), nil
+	}
+
+	if rVal.Kind() != reflect.Chan {
+		return nil, fmt.Errorf("value is not a channel: %T", value)
+	}
+
+	if rVal.Type().ChanDir()&reflect.RecvDir == 0 {
+		return nil, fmt.Errorf("cannot receive from send-only channel %T", value)
+	}
+
+	var (
+		limit = iterLimit(c)
+		nodes = make([]Node, 0)
+		cases = []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: rVal},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		}
+	)
+
+	for {
+		var chosen, item, ok = reflect.Select(cases)
+		if chosen == 1 {
+			return nil, wrapPathError(ctx.Err(), len(nodes))
+		}
+
+		if !ok {
+			return NewListNode(nodes), nil
+		}
+
+		if len(nodes) >= limit {
+			return nil, fmt.Errorf("channel received more than %d items", limit)
+		}
+
+		var node, err = c.BuildNode(ctx, item.Interface())
+		if err != nil {
+			return nil, wrapPathError(err, len(nodes))
+		}
+
+		nodes = append(nodes, node)
+	}
+}
@@ -0,0 +1,82 @@
+package telepath
+
+import (
+	"context"
+	"sync"
+)
+
+// LazyValue is a value which is only computed when it is actually packed.
+//
+// The function is called at most once per pack, the result is cached by the identity
+// of the *LazyValue so that using the same lazy value twice results in a reference.
+type LazyValue struct {
+	Func func(ctx context.Context) (interface{}, error)
+}
+
+func Lazy(fn func(ctx context.Context) (interface{}, error)) *LazyValue {
+	return &LazyValue{Func: fn}
+}
+
+func (l *LazyValue) Resolve(ctx context.Context) (interface{}, error) {
+	if l.Func == nil {
+		return nil, nil
+	}
+	return l.Func(ctx)
+}
+
+type lazyResult struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// resolveLazy resolves the lazy value once for this context,
+// even if it is used by multiple goroutines at the same time.
+func (c *ValueContext) resolveLazy(ctx context.Context, l *LazyValue) (interface{}, error) {
+	c.mu.Lock()
+	if c.lazyResults == nil {
+		c.lazyResults = make(map[*LazyValue]*lazyResult)
+	}
+	var result, ok = c.lazyResults[l]
+	if !ok {
+		result = &lazyResult{}
+		c.lazyResults[l] = result
+	}
+	c.mu.Unlock()
+
+	result.once.Do(func() {
+		result.value, result.err = l.Resolve(ctx)
+	})
+
+	return result.value, result.err
+}
+
+type LazyTelepathAdapter struct{}
+
+func LazyAdapter() *LazyTelepathAdapter {
+	return &LazyTelepathAdapter{}
+}
+
+func (m *LazyTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
+	var l, ok = value.(*LazyValue)
+	if !ok || l == nil {
+		return NullNode(), nil
+	}
+
+	var (
+		resolved interface{}
+		err      error
+	)
+
+	if valueCtx, ok := c.(*ValueContext); ok {
+		resolved, err = valueCtx.resolveLazy(ctx, l)
+	} else {
+		resolved, err = l.Resolve(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c.BuildNode(ctx, resolved)
+}
@@ -0,0 +1,202 @@
+package telepath
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// A BatchLoader loads the values for all keys at once.
+//
+// It must return exactly one value per key, in the same order as the keys.
+type BatchLoader func(ctx context.Context, keys []interface{}) ([]interface{}, error)
+
+// LoadValue is a placeholder for a value which is loaded by the named BatchLoader.
+//
+// Adapters return these from GetJSArgs instead of querying related data for each object,
+// all keys are collected while the tree is built, loaded with a single call per loader
+// and only then are the nodes for the loaded values built.
+//
+// Keys must be comparable, packing fails with an error otherwise.
+type LoadValue struct {
+	Loader string
+	Key    interface{}
+}
+
+func Load(loader string, key interface{}) LoadValue {
+	return LoadValue{Loader: loader, Key: key}
+}
+
+// AddLoader registers a BatchLoader which can be referred to by name from Load().
+func (c *JSContext) AddLoader(name string, loader BatchLoader) {
+	if c.Loaders == nil {
+		c.Loaders = make(map[string]BatchLoader)
+	}
+	c.Loaders[name] = loader
+}
+
+type LoadTelepathAdapter struct{}
+
+func LoadAdapter() *LoadTelepathAdapter {
+	return &LoadTelepathAdapter{}
+}
+
+func (m *LoadTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
+	var load, ok = value.(LoadValue)
+	if !ok {
+		return nil, fmt.Errorf("value is not of type %T", load)
+	}
+
+	valueCtx, ok := c.(*ValueContext)
+	if !ok {
+		return nil, fmt.Errorf("batch loading is only supported by %T, got %T", valueCtx, c)
+	}
+
+	if _, ok = valueCtx.ParentContext.Loaders[load.Loader]; !ok {
+		return nil, fmt.Errorf("no loader registered with name %q", load.Loader)
+	}
+
+	if load.Key != nil && !reflect.ValueOf(load.Key).Comparable() {
+		return nil, fmt.Errorf("key for loader %q is not comparable: %T", load.Loader, load.Key)
+	}
+
+	var node = NewDeferredNode(load)
+	valueCtx.mu.Lock()
+	valueCtx.pending = append(valueCtx.pending, node)
+	valueCtx.mu.Unlock()
+	return node, nil
+}
+
+// resolvePending loads the values for all pending nodes.
+//
+// Building the loaded values might result in new pending nodes,
+// these are loaded in the next round until nothing is left.
+//
+// Errors are returned with the path from the root to the failing node.
+func (c *ValueContext) resolvePending(ctx context.Context, root Node) error {
+	for len(c.pending) > 0 {
+		var (
+			pending = c.pending
+			keys    = make(map[string][]interface{})
+			indices = make(map[string]map[interface{}]int)
+			values  = make(map[string][]interface{})
+			names   = make([]string, 0)
+		)
+
+		c.pending = nil
+
+		for _, node := range pending {
+			var load = node.Load
+			if _, ok := indices[load.Loader]; !ok {
+				indices[load.Loader] = make(map[interface{}]int)
+				names = append(names, load.Loader)
+			}
+			if _, ok := indices[load.Loader][load.Key]; !ok {
+				indices[load.Loader][load.Key] = len(keys[load.Loader])
+				keys[load.Loader] = append(keys[load.Loader], load.Key)
+			}
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			if err := ctx.Err(); err != nil {
+				return &PathError{Err: err}
+			}
+
+			var loaded, err = c.ParentContext.Loaders[name](ctx, keys[name])
+			if err != nil {
+				return withNodePath(fmt.Errorf("loader %q: %w", name, err), root, firstPending(pending, name))
+			}
+
+			if len(loaded) != len(keys[name]) {
+				return withNodePath(fmt.Errorf(
+					"loader %q returned %d values for %d keys",
+					name, len(loaded), len(keys[name]),
+				), root, firstPending(pending, name))
+			}
+
+			values[name] = loaded
+		}
+
+		for _, node := range pending {
+			var (
+				load  = node.Load
+				value = values[load.Loader][indices[load.Loader][load.Key]]
+			)
+
+			var resolved, err = c.BuildNode(ctx, value)
+			if err != nil {
+				return withNodePath(err, root, node)
+			}
+
+			node.Resolved = resolved
+		}
+	}
+
+	return nil
+}
+
+func firstPending(pending []*DeferredNode, loader string) Node {
+	for _, node := range pending {
+		if node.Load.Loader == loader {
+			return node
+		}
+	}
+	return nil
+}
+
+// withNodePath wraps err with the path from the root to the node.
+func withNodePath(err error, root, node Node) error {
+	var path, _ = nodePath(root, node, make(map[Node]struct{}))
+	if _, ok := err.(*PathError); !ok && len(path) == 0 {
+		return &PathError{Err: err}
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		err = wrapPathError(err, path[i])
+	}
+	return err
+}
+
+// nodePath looks up the first path from node to target,
+// the loaded values of deferred nodes are part of the path as if they were packed directly.
+func nodePath(node, target Node, seen map[Node]struct{}) ([]interface{}, bool) {
+	if node == target {
+		return []interface{}{}, true
+	}
+
+	var container, ok = node.(ContainerNode)
+	if !ok {
+		return nil, false
+	}
+
+	if _, ok = seen[node]; ok {
+		return nil, false
+	}
+	seen[node] = struct{}{}
+
+	var keys []string
+	if dict, ok := node.(*DictNode); ok {
+		keys = dict.keys()
+	}
+
+	for i, child := range container.Children() {
+		var path, found = nodePath(child, target, seen)
+		if !found {
+			continue
+		}
+
+		switch node.(type) {
+		case *DeferredNode:
+			return path, true
+		case *DictNode:
+			return append([]interface{}{keys[i]}, path...), true
+		case *ObjectNode:
+			return append([]interface{}{"_args", i}, path...), true
+		}
+		return append([]interface{}{i}, path...), true
+	}
+
+	return nil, false
+}
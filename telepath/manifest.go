@@ -0,0 +1,161 @@
+package telepath
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Manifest describes every JS constructor known to a registry.
+type Manifest struct {
+	Constructors []ManifestConstructor `json:"constructors"`
+}
+
+// ManifestConstructor describes a JS constructor, the Go types it is registered for,
+// its media and its argument schema. Args is nil if the adapter does not describe its arguments.
+type ManifestConstructor struct {
+	Name  string        `json:"name"`
+	Types []string      `json:"types"`
+	Media ManifestMedia `json:"media"`
+	Args  []ManifestArg `json:"args"`
+}
+
+type ManifestMedia struct {
+	CSS []string `json:"css"`
+	JS  []string `json:"js"`
+}
+
+type ManifestArg struct {
+	Name     string        `json:"name"`
+	Type     string        `json:"type,omitempty"`
+	TSType   string        `json:"tsType"`
+	Optional bool          `json:"optional,omitempty"`
+	Fields   []ManifestArg `json:"fields,omitempty"`
+}
+
+// mediaAdapter is implemented by adapters which add media to the context, like ObjectAdapter.
+type mediaAdapter interface {
+	GetMedia(obj interface{}) Media
+}
+
+// Manifest returns a JSON serialisable description of every JS constructor in the registry,
+// sorted by constructor name.
+func (r *AdapterRegistry) Manifest() (*Manifest, error) {
+	var constructors, err = r.Constructors()
+	if err != nil {
+		return nil, err
+	}
+
+	var types = make(map[string][]string, len(constructors))
+	var addType = func(a Adapter, t reflect.Type) {
+		if adapter, ok := a.(ConstructorAdapter); ok {
+			var name, _ = adapter.Constructor()
+			if name != "" {
+				types[name] = append(types[name], goTypeName(t))
+			}
+		}
+	}
+
+	for _, adapters := range r.adapters {
+		for t, a := range adapters {
+			addType(a, t)
+		}
+	}
+
+	for t, a := range r.iFaces {
+		addType(a, t)
+	}
+
+	var manifest = &Manifest{
+		Constructors: make([]ManifestConstructor, 0, len(constructors)),
+	}
+
+	for name, adapter := range constructors {
+		var _, args = adapter.Constructor()
+		var constructor = ManifestConstructor{
+			Name:  name,
+			Types: types[name],
+			Media: ManifestMedia{CSS: []string{}, JS: []string{}},
+			Args:  r.manifestArgs(args),
+		}
+		sort.Strings(constructor.Types)
+
+		if m, ok := adapter.(mediaAdapter); ok {
+			if media := m.GetMedia(nil); media != nil {
+				for _, css := range media.CSS() {
+					constructor.Media.CSS = append(constructor.Media.CSS, string(css))
+				}
+				for _, js := range media.JS() {
+					constructor.Media.JS = append(constructor.Media.JS, string(js))
+				}
+			}
+		}
+
+		manifest.Constructors = append(manifest.Constructors, constructor)
+	}
+
+	sort.Slice(manifest.Constructors, func(i, j int) bool {
+		return manifest.Constructors[i].Name < manifest.Constructors[j].Name
+	})
+
+	return manifest, nil
+}
+
+// WriteManifest writes the manifest of the registry as indented JSON.
+func (r *AdapterRegistry) WriteManifest(w io.Writer) error {
+	var manifest, err = r.Manifest()
+	if err != nil {
+		return err
+	}
+
+	var enc = json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(manifest)
+}
+
+func (r *AdapterRegistry) manifestArgs(args []JSArg) []ManifestArg {
+	if args == nil {
+		return nil
+	}
+
+	var result = make([]ManifestArg, 0, len(args))
+	for _, arg := range args {
+		var manifestArg = ManifestArg{
+			Name:     arg.Name,
+			Optional: arg.Optional,
+		}
+
+		if arg.Fields != nil {
+			manifestArg.Fields = r.manifestArgs(arg.Fields)
+			manifestArg.TSType = "{" + joinMembers(r.typeScriptMembers(arg.Fields)) + "}"
+		} else {
+			manifestArg.TSType = r.typeScriptType(arg.Type, nil)
+			if arg.Type != nil {
+				manifestArg.Type = goTypeName(arg.Type)
+			}
+		}
+
+		result = append(result, manifestArg)
+	}
+	return result
+}
+
+// goTypeName returns the name of the type including the full import path of named types.
+func goTypeName(t reflect.Type) string {
+	if t.Name() != "" && t.PkgPath() != "" {
+		return t.PkgPath() + "." + t.Name()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + goTypeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + goTypeName(t.Elem())
+	case reflect.Map:
+		return "map[" + goTypeName(t.Key()) + "]" + goTypeName(t.Elem())
+	}
+
+	return t.String()
+}
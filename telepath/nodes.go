@@ -1,8 +1,11 @@
 package telepath
 
 import (
+	"bytes"
+	"encoding/json"
 	"reflect"
 	"slices"
+	"sort"
 
 	"github.com/google/uuid"
 )
@@ -81,6 +84,10 @@ func (m *TelepathValueNode) GetValue() interface{} {
 	return m.Value
 }
 
+func (m *TelepathValueNode) Emit() any {
+	return m.EmitCompact()
+}
+
 func (m *TelepathValueNode) EmitVerbose() TelepathValue {
 	return TelepathValue{Val: m.GetValue()}
 }
@@ -89,6 +96,149 @@ func (m *TelepathValueNode) EmitCompact() any {
 	return m.GetValue()
 }
 
+// PrimitiveNode holds a single primitive value (numbers, booleans and strings).
+//
+// Primitive values are never turned into references, so the node
+// only needs to hold on to the value.
+type PrimitiveNode struct {
+	Value interface{}
+}
+
+func NewPrimitiveNode(value interface{}) *PrimitiveNode {
+	return &PrimitiveNode{Value: value}
+}
+
+func (m *PrimitiveNode) GetValue() interface{} {
+	return m.Value
+}
+
+func (m *PrimitiveNode) UseID() bool {
+	return false
+}
+
+func (m *PrimitiveNode) SetID(id int) {}
+
+func (m *PrimitiveNode) GetID() int {
+	return 0
+}
+
+func (m *PrimitiveNode) Emit() any {
+	return m.Value
+}
+
+func (m *PrimitiveNode) EmitVerbose() TelepathValue {
+	return TelepathValue{Val: m.Value}
+}
+
+func (m *PrimitiveNode) EmitCompact() any {
+	return m.Value
+}
+
+type PrimitiveListValue interface {
+	PrimitiveNodeValue | string
+}
+
+// PrimitiveListNode holds a slice of primitive values without building a node for each item.
+//
+// The slice is emitted as a _list, like ListNode does.
+type PrimitiveListNode[T PrimitiveListValue] struct {
+	Values []T
+	ID     int
+	Seen   bool
+}
+
+func NewPrimitiveListNode[T PrimitiveListValue](values []T) *PrimitiveListNode[T] {
+	return &PrimitiveListNode[T]{Values: values}
+}
+
+func (m *PrimitiveListNode[T]) GetValue() interface{} {
+	return m.Values
+}
+
+func (m *PrimitiveListNode[T]) UseID() bool {
+	return m.ID != 0 && m.Seen
+}
+
+func (m *PrimitiveListNode[T]) SetID(id int) {
+	m.ID = id
+}
+
+func (m *PrimitiveListNode[T]) GetID() int {
+	return m.ID
+}
+
+func (m *PrimitiveListNode[T]) Emit() any {
+	if m.UseID() {
+		return TelepathValue{Ref: m.ID}
+	}
+
+	m.Seen = true
+
+	var result = m.EmitVerbose()
+	if m.ID != 0 {
+		result.ID = m.ID
+	}
+	return result
+}
+
+func (m *PrimitiveListNode[T]) EmitVerbose() TelepathValue {
+	var result = TelepathValue{List: make([]interface{}, len(m.Values))}
+	for i, value := range m.Values {
+		result.List[i] = value
+	}
+	return result
+}
+
+func (m *PrimitiveListNode[T]) EmitCompact() any {
+	var result = make([]interface{}, len(m.Values))
+	for i, value := range m.Values {
+		result[i] = value
+	}
+	return result
+}
+
+// RawJSONNode emits JSON which was already encoded.
+//
+// Objects and lists are wrapped in a _val so that the unpacker
+// does not interpret any of their keys.
+type RawJSONNode struct {
+	Value json.RawMessage
+}
+
+func NewRawJSONNode(value json.RawMessage) *RawJSONNode {
+	return &RawJSONNode{Value: value}
+}
+
+func (m *RawJSONNode) GetValue() interface{} {
+	return m.Value
+}
+
+func (m *RawJSONNode) UseID() bool {
+	return false
+}
+
+func (m *RawJSONNode) SetID(id int) {}
+
+func (m *RawJSONNode) GetID() int {
+	return 0
+}
+
+func (m *RawJSONNode) Emit() any {
+	return m.EmitCompact()
+}
+
+func (m *RawJSONNode) EmitVerbose() TelepathValue {
+	return TelepathValue{Val: m.Value}
+}
+
+func (m *RawJSONNode) EmitCompact() any {
+	var trimmed = bytes.TrimLeft(m.Value, " \t\r\n")
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return m.EmitVerbose()
+	}
+	return m.Value
+}
+
 type UUIDNode struct {
 	*TelepathValueNode
 }
@@ -136,6 +286,14 @@ func (m *StringNode) UseID() bool {
 	return rVal.Len() >= STRING_REF_MIN_LENGTH && m.ID != 0 && m.Seen
 }
 
+// SetID is ignored for strings shorter than STRING_REF_MIN_LENGTH,
+// these are emitted in full every time they are used.
+func (m *StringNode) SetID(id int) {
+	if reflect.ValueOf(m.GetValue()).Len() >= STRING_REF_MIN_LENGTH {
+		m.TelepathValueNode.SetID(id)
+	}
+}
+
 func NewStringNode(value interface{}) *StringNode {
 	return &StringNode{
 		TelepathValueNode: NewTelepathValueNode(value),
@@ -222,6 +380,10 @@ func NewObjectNode(constructor string, args []Node) *ObjectNode {
 	}
 }
 
+func (m *ObjectNode) Children() []Node {
+	return m.Args
+}
+
 func (m *ObjectNode) SetID(id int) {
 	m.ID = id
 	m.UseIdentifier = true
@@ -266,8 +428,33 @@ type DictNode struct {
 	*TelepathValueNode
 }
 
+// keys returns the keys of the dict in sorted order,
+// so references are assigned and emitted in the same order every time.
+func (m *DictNode) keys() []string {
+	var (
+		value = m.Value.(map[string]Node)
+		keys  = make([]string, 0, len(value))
+	)
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *DictNode) Children() []Node {
+	var (
+		value = m.Value.(map[string]Node)
+		nodes = make([]Node, 0, len(value))
+	)
+	for _, key := range m.keys() {
+		nodes = append(nodes, value[key])
+	}
+	return nodes
+}
+
 func (m *DictNode) UseID() bool {
-	return m.ID != 0 && m.UseIdentifier
+	return m.ID != 0 && m.Seen
 }
 
 func (m *DictNode) SetID(id int) {
@@ -296,9 +483,10 @@ func (m *DictNode) Emit() any {
 }
 
 func (m *DictNode) EmitVerbose() TelepathValue {
-	var result = TelepathValue{Dict: make(map[string]interface{})}
-	for key, value := range m.Value.(map[string]Node) {
-		result.Dict[key] = value.Emit()
+	var value = m.Value.(map[string]Node)
+	var result = TelepathValue{Dict: make(map[string]interface{}, len(value))}
+	for _, key := range m.keys() {
+		result.Dict[key] = value[key].Emit()
 	}
 	return result
 }
@@ -306,7 +494,7 @@ func (m *DictNode) EmitVerbose() TelepathValue {
 func (m *DictNode) EmitCompact() any {
 	var (
 		hasReservedKey = false
-		result         = make(map[string]interface{})
+		result         = make(map[string]interface{}, len(m.Value.(map[string]Node)))
 	)
 
 	for key := range m.Value.(map[string]Node) {
@@ -318,8 +506,9 @@ func (m *DictNode) EmitCompact() any {
 		}
 	}
 
-	for key, value := range m.Value.(map[string]Node) {
-		result[key] = value.Emit()
+	var value = m.Value.(map[string]Node)
+	for _, key := range m.keys() {
+		result[key] = value[key].Emit()
 	}
 
 	return result
@@ -335,6 +524,10 @@ func NewListNode(value []Node) *ListNode {
 	}
 }
 
+func (m *ListNode) Children() []Node {
+	return m.Value.([]Node)
+}
+
 func (m *ListNode) GetValue() interface{} {
 	return m.Value
 }
@@ -363,7 +556,7 @@ func (m *ListNode) SetID(id int) {
 }
 
 func (m *ListNode) EmitVerbose() TelepathValue {
-	var result = TelepathValue{List: make([]interface{}, 0)}
+	var result = TelepathValue{List: make([]interface{}, 0, len(m.Value.([]Node)))}
 	for _, value := range m.Value.([]Node) {
 		result.List = append(result.List, value.Emit())
 	}
@@ -371,9 +564,72 @@ func (m *ListNode) EmitVerbose() TelepathValue {
 }
 
 func (m *ListNode) EmitCompact() any {
-	var result = make([]interface{}, 0)
+	var result = make([]interface{}, 0, len(m.Value.([]Node)))
 	for _, value := range m.Value.([]Node) {
 		result = append(result, value.Emit())
 	}
 	return result
 }
+
+// DeferredNode is a placeholder for a node which is built after the rest of the tree,
+// once built all calls are forwarded to the resolved node.
+type DeferredNode struct {
+	Load     LoadValue
+	Resolved Node
+}
+
+func NewDeferredNode(load LoadValue) *DeferredNode {
+	return &DeferredNode{Load: load}
+}
+
+func (m *DeferredNode) Children() []Node {
+	if m.Resolved == nil {
+		return nil
+	}
+	return []Node{m.Resolved}
+}
+
+func (m *DeferredNode) GetValue() interface{} {
+	if m.Resolved == nil {
+		return nil
+	}
+	return m.Resolved.GetValue()
+}
+
+func (m *DeferredNode) UseID() bool {
+	return m.Resolved != nil && m.Resolved.UseID()
+}
+
+func (m *DeferredNode) SetID(id int) {
+	if m.Resolved != nil {
+		m.Resolved.SetID(id)
+	}
+}
+
+func (m *DeferredNode) GetID() int {
+	if m.Resolved == nil {
+		return 0
+	}
+	return m.Resolved.GetID()
+}
+
+func (m *DeferredNode) Emit() any {
+	if m.Resolved == nil {
+		return nil
+	}
+	return m.Resolved.Emit()
+}
+
+func (m *DeferredNode) EmitVerbose() TelepathValue {
+	if m.Resolved == nil {
+		return TelepathValue{}
+	}
+	return m.Resolved.EmitVerbose()
+}
+
+func (m *DeferredNode) EmitCompact() any {
+	if m.Resolved == nil {
+		return nil
+	}
+	return m.Resolved.EmitCompact()
+}
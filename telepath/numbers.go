@@ -0,0 +1,114 @@
+package telepath
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// FloatPolicy decides how NaN and (negative) infinity are packed,
+// JSON has no representation for these values.
+type FloatPolicy int
+
+const (
+	FLOAT_POLICY_NONE   FloatPolicy = iota // Emitted as-is, encoding/json will fail to marshal these
+	FLOAT_POLICY_NULL                      // Emitted as null
+	FLOAT_POLICY_MARKER                    // Emitted as a FLOAT_JS_CONSTRUCTOR object which is unpacked to NaN, Infinity or -Infinity
+)
+
+// BigIntPolicy decides how integers which JavaScript cannot represent exactly are packed.
+type BigIntPolicy int
+
+const (
+	BIGINT_POLICY_NONE   BigIntPolicy = iota // Emitted as a number, JavaScript might lose precision
+	BIGINT_POLICY_STRING                     // Emitted as a string
+	BIGINT_POLICY_BIGINT                     // Emitted as a BIGINT_JS_CONSTRUCTOR object which is unpacked to a BigInt
+)
+
+const (
+	FLOAT_JS_CONSTRUCTOR  = "telepath.Float"  // Registered by default in the bundled telepath.js
+	BIGINT_JS_CONSTRUCTOR = "telepath.BigInt" // Registered by default in the bundled telepath.js
+
+	MAX_SAFE_INTEGER = 1<<53 - 1 // Number.MAX_SAFE_INTEGER in JavaScript
+)
+
+// numberPolicies returns the policies of the JSContext which is being packed to.
+func numberPolicies(c Context) (FloatPolicy, BigIntPolicy) {
+	if valueCtx, ok := c.(*ValueContext); ok && valueCtx.ParentContext != nil {
+		return valueCtx.ParentContext.FloatPolicy, valueCtx.ParentContext.BigIntPolicy
+	}
+	return FLOAT_POLICY_NONE, BIGINT_POLICY_NONE
+}
+
+// buildNumberNode builds a node for numbers which need special treatment according to the policies,
+// ok is false for any other value.
+func buildNumberNode(value interface{}, floatPolicy FloatPolicy, bigIntPolicy BigIntPolicy) (node Node, ok bool) {
+	if floatPolicy == FLOAT_POLICY_NONE && bigIntPolicy == BIGINT_POLICY_NONE {
+		return nil, false
+	}
+
+	var rVal = reflect.ValueOf(value)
+	switch rVal.Kind() {
+	case reflect.Float32, reflect.Float64:
+		var f = rVal.Float()
+		if floatPolicy == FLOAT_POLICY_NONE || !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return nil, false
+		}
+		if floatPolicy == FLOAT_POLICY_NULL {
+			return NullNode(), true
+		}
+		return NewObjectNode(FLOAT_JS_CONSTRUCTOR, []Node{
+			NewPrimitiveNode(formatSpecialFloat(f)),
+		}), true
+
+	case reflect.Int, reflect.Int64:
+		var i = rVal.Int()
+		if bigIntPolicy == BIGINT_POLICY_NONE || i >= -MAX_SAFE_INTEGER && i <= MAX_SAFE_INTEGER {
+			return nil, false
+		}
+		return newBigIntNode(strconv.FormatInt(i, 10), bigIntPolicy), true
+
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		var u = rVal.Uint()
+		if bigIntPolicy == BIGINT_POLICY_NONE || u <= MAX_SAFE_INTEGER {
+			return nil, false
+		}
+		return newBigIntNode(strconv.FormatUint(u, 10), bigIntPolicy), true
+	}
+
+	return nil, false
+}
+
+func formatSpecialFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return "NaN"
+}
+
+func newBigIntNode(value string, policy BigIntPolicy) Node {
+	if policy == BIGINT_POLICY_STRING {
+		return NewPrimitiveNode(value)
+	}
+	return NewObjectNode(BIGINT_JS_CONSTRUCTOR, []Node{
+		NewPrimitiveNode(value),
+	})
+}
+
+// needsNumberNodes reports if any of the values needs special treatment according to the policies.
+func needsNumberNodes[T PrimitiveListValue](values []T, floatPolicy FloatPolicy, bigIntPolicy BigIntPolicy) bool {
+	if floatPolicy == FLOAT_POLICY_NONE && bigIntPolicy == BIGINT_POLICY_NONE {
+		return false
+	}
+
+	for _, value := range values {
+		if _, ok := buildNumberNode(value, floatPolicy, bigIntPolicy); ok {
+			return true
+		}
+	}
+
+	return false
+}
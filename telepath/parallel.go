@@ -0,0 +1,144 @@
+package telepath
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	_ ContainerNode = (*ListNode)(nil)
+	_ ContainerNode = (*DictNode)(nil)
+	_ ContainerNode = (*ObjectNode)(nil)
+	_ ContainerNode = (*DeferredNode)(nil)
+)
+
+// buildNodes builds a node for each of the n items returned by item(i).
+//
+// If the context allows for it, the items are built on the context's bounded pool of workers.
+// The returned nodes are always in the same order as the items, errors are wrapped with seg(i).
+func buildNodes(ctx context.Context, c Context, n int, item func(i int) interface{}, seg func(i int) interface{}) ([]Node, error) {
+	var nodes = make([]Node, n)
+
+	var valueCtx, ok = c.(*ValueContext)
+	if !ok || !valueCtx.buildParallel(n) {
+		for i := 0; i < n; i++ {
+			var node, err = c.BuildNode(ctx, item(i))
+			if err != nil {
+				return nil, wrapPathError(err, seg(i))
+			}
+			nodes[i] = node
+		}
+		return nodes, nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		next     atomic.Int64
+		failed   atomic.Bool
+		errs     = make([]error, n)
+		panicked = make(chan interface{}, cap(valueCtx.workers))
+	)
+
+	var work = func() {
+		for !failed.Load() {
+			var i = int(next.Add(1) - 1)
+			if i >= n {
+				return
+			}
+
+			var node, err = c.BuildNode(ctx, item(i))
+			if err != nil {
+				errs[i] = err
+				failed.Store(true)
+				return
+			}
+			nodes[i] = node
+		}
+	}
+
+	// Start as many workers as there are free slots, the current goroutine
+	// also works so nested containers never wait on each other.
+spawn:
+	for spawned := 0; spawned < n-1; spawned++ {
+		select {
+		case valueCtx.workers <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						failed.Store(true)
+						panicked <- r
+					}
+					<-valueCtx.workers
+					wg.Done()
+				}()
+				work()
+			}()
+		default:
+			break spawn
+		}
+	}
+
+	work()
+	wg.Wait()
+
+	select {
+	case r := <-panicked:
+		panic(r)
+	default:
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, wrapPathError(err, seg(i))
+		}
+	}
+
+	return nodes, nil
+}
+
+func (c *ValueContext) buildParallel(n int) bool {
+	if c.workers == nil {
+		return false
+	}
+
+	var minItems = c.ParentContext.ParallelMinItems
+	if minItems <= 0 {
+		minItems = PARALLEL_MIN_ITEMS
+	}
+
+	return n >= minItems
+}
+
+// assignIDs walks the tree depth-first and assigns an ID to every node that is encountered more than once.
+//
+// This results in the same IDs as when the tree would have been built sequentially.
+func (c *ValueContext) assignIDs(root Node) {
+	var (
+		seen = make(map[Node]struct{})
+		walk func(node Node)
+	)
+
+	walk = func(node Node) {
+		if _, ok := seen[node]; ok {
+			if node.GetID() == 0 {
+				node.SetID(c.NextID + 1)
+				if node.GetID() != 0 {
+					c.NextID++
+				}
+			}
+			return
+		}
+
+		seen[node] = struct{}{}
+
+		if container, ok := node.(ContainerNode); ok {
+			for _, child := range container.Children() {
+				walk(child)
+			}
+		}
+	}
+
+	walk(root)
+}
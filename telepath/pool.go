@@ -0,0 +1,94 @@
+package telepath
+
+import "sync"
+
+const (
+	POOL_MAX_NODES      = 4096 // Contexts which built more nodes than this are not put back into the pool
+	PRIMITIVE_CHUNK_MIN = 16   // Size of the first chunk of primitive nodes, following chunks double in size
+	PRIMITIVE_CHUNK_MAX = 1024 // Maximum size of a chunk of primitive nodes
+	PRIMITIVE_CHUNKS    = 8    // Amount of chunks of primitive nodes kept after a reset
+)
+
+var valueContextPool = sync.Pool{
+	New: func() any {
+		return &ValueContext{
+			Nodes:     make(map[uintptr]Node),
+			RawValues: make(map[uintptr]interface{}),
+		}
+	},
+}
+
+// AcquireValueContext returns a ValueContext from the pool.
+//
+// Release() must be called once the packed value has been emitted,
+// after that the context and any of the nodes it built must no longer be used.
+func AcquireValueContext(c *JSContext) *ValueContext {
+	var v = valueContextPool.Get().(*ValueContext)
+	v.init(c)
+	return v
+}
+
+// Release resets the context and puts it back into the pool.
+//
+// Primitive nodes are reused by the next pack, so none of the nodes built by the
+// context may be used after calling Release. The values returned by Emit() do not
+// refer to any of the nodes, these can still be used.
+func (c *ValueContext) Release() {
+	var reuse = len(c.Nodes) <= POOL_MAX_NODES
+
+	clear(c.Nodes)
+	clear(c.RawValues)
+	c.ParentContext = nil
+	c.AdapterRegistry = nil
+	c.NextID = 0
+	c.NodeCount = 0
+	c.workers = nil
+	c.lazyResults = nil
+	c.pending = nil
+	c.primitives.reset()
+
+	if reuse {
+		valueContextPool.Put(c)
+	}
+}
+
+// primitiveSlab allocates primitive nodes in chunks, which are reused after a reset.
+type primitiveSlab struct {
+	chunks  [][]PrimitiveNode
+	current int
+}
+
+func (s *primitiveSlab) alloc(value interface{}) *PrimitiveNode {
+	for s.current < len(s.chunks) {
+		var chunk = s.chunks[s.current]
+		if len(chunk) < cap(chunk) {
+			chunk = append(chunk, PrimitiveNode{Value: value})
+			s.chunks[s.current] = chunk
+			return &chunk[len(chunk)-1]
+		}
+		s.current++
+	}
+
+	var size = PRIMITIVE_CHUNK_MIN
+	if len(s.chunks) > 0 {
+		size = min(cap(s.chunks[len(s.chunks)-1])*2, PRIMITIVE_CHUNK_MAX)
+	}
+
+	var chunk = make([]PrimitiveNode, 1, size)
+	chunk[0] = PrimitiveNode{Value: value}
+	s.chunks = append(s.chunks, chunk)
+	s.current = len(s.chunks) - 1
+	return &chunk[0]
+}
+
+func (s *primitiveSlab) reset() {
+	for i, chunk := range s.chunks {
+		clear(chunk)
+		s.chunks[i] = chunk[:0]
+	}
+	if len(s.chunks) > PRIMITIVE_CHUNKS {
+		clear(s.chunks[PRIMITIVE_CHUNKS:])
+		s.chunks = s.chunks[:PRIMITIVE_CHUNKS]
+	}
+	s.current = 0
+}
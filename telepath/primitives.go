@@ -0,0 +1,85 @@
+package telepath
+
+import (
+	"context"
+	"reflect"
+)
+
+// buildPrimitive builds a node for a primitive value without reflection,
+// ok is false if a custom adapter was registered for the value's type.
+func (c *ValueContext) buildPrimitive(ctx context.Context, value interface{}) (node Node, ok bool) {
+	var adapter Adapter
+	if adapter, ok = c.AdapterRegistry.Find(ctx, value); !ok {
+		return nil, false
+	}
+
+	switch adapter.(type) {
+	case *BaseTelepathAdapter, *StringTelepathAdapter:
+		if node, ok = buildNumberNode(value, c.ParentContext.FloatPolicy, c.ParentContext.BigIntPolicy); ok {
+			return node, true
+		}
+
+		if c.workers == nil {
+			return c.primitives.alloc(value), true
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.primitives.alloc(value), true
+	}
+
+	return nil, false
+}
+
+// buildPrimitiveList builds a single node for slices of primitive values,
+// ok is false if a custom adapter was registered for the item type.
+func buildPrimitiveList(c Context, value interface{}) (node Node, ok bool) {
+	switch v := value.(type) {
+	case []bool:
+		return newPrimitiveList(c, v)
+	case []int:
+		return newPrimitiveList(c, v)
+	case []int8:
+		return newPrimitiveList(c, v)
+	case []int16:
+		return newPrimitiveList(c, v)
+	case []int32:
+		return newPrimitiveList(c, v)
+	case []int64:
+		return newPrimitiveList(c, v)
+	case []uint:
+		return newPrimitiveList(c, v)
+	case []uint16:
+		return newPrimitiveList(c, v)
+	case []uint32:
+		return newPrimitiveList(c, v)
+	case []uint64:
+		return newPrimitiveList(c, v)
+	case []float32:
+		return newPrimitiveList(c, v)
+	case []float64:
+		return newPrimitiveList(c, v)
+	case []string:
+		return newPrimitiveList(c, v)
+	}
+	return nil, false
+}
+
+func newPrimitiveList[T PrimitiveListValue](c Context, values []T) (Node, bool) {
+	var adapter, ok = c.Registry().Resolve(reflect.TypeOf((*T)(nil)).Elem())
+	if !ok {
+		return nil, false
+	}
+
+	var floatPolicy, bigIntPolicy = numberPolicies(c)
+	if needsNumberNodes(values, floatPolicy, bigIntPolicy) {
+		return nil, false
+	}
+
+	switch adapter.(type) {
+	case *BaseTelepathAdapter, *StringTelepathAdapter:
+		return NewPrimitiveListNode(values), true
+	}
+
+	return nil, false
+}
@@ -2,7 +2,10 @@ package telepath
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
+	"sync"
+	"sync/atomic"
 
 	"github.com/google/uuid"
 )
@@ -30,11 +33,18 @@ func init() {
 		rTypFloat64 = reflect.TypeOf(float64(0))
 		rTypString  = reflect.TypeOf(string(""))
 		rTypSlice   = reflect.TypeOf([]interface{}{})
+		rTypArray   = reflect.TypeOf([0]interface{}{})
 		rTypMap     = reflect.TypeOf(map[string]interface{}{})
+		rTypBytes   = reflect.TypeOf([]byte{})
+		rTypRawJSON = reflect.TypeOf(json.RawMessage{})
 
 		// Interface types
 		rTypError = reflect.TypeOf((*error)(nil)).Elem()
 
+		// Telepath types
+		rTypLazy = reflect.TypeOf((*LazyValue)(nil))
+		rTypLoad = reflect.TypeOf(LoadValue{})
+
 		// Third party types
 		rTypUUID = reflect.TypeOf(uuid.Nil)
 	)
@@ -71,6 +81,8 @@ func init() {
 	specificAdapterMap[rTypFloat64.Kind()][rTypFloat64] = BaseAdapter()
 	specificAdapterMap[rTypString.Kind()][rTypString] = StringAdapter()
 	specificAdapterMap[rTypSlice.Kind()][rTypSlice] = SliceAdapter()
+	specificAdapterMap[rTypBytes.Kind()][rTypBytes] = BytesAdapter()
+	specificAdapterMap[rTypRawJSON.Kind()][rTypRawJSON] = RawJSONAdapter()
 	specificAdapterMap[rTypMap.Kind()][rTypMap] = MapAdapter()
 
 	defaultAdapterMap[rTypBool.Kind()] = BaseAdapter()
@@ -88,22 +100,52 @@ func init() {
 	defaultAdapterMap[rTypFloat64.Kind()] = BaseAdapter()
 	defaultAdapterMap[rTypString.Kind()] = StringAdapter()
 	defaultAdapterMap[rTypSlice.Kind()] = SliceAdapter()
+	defaultAdapterMap[rTypArray.Kind()] = ArrayAdapter()
 	defaultAdapterMap[rTypMap.Kind()] = MapAdapter()
 
 	// Interface adapters
 	iFaceAdapterMap[rTypError] = ErrorAdapter()
 
+	// Telepath adapters
+	specificAdapterMap[rTypLazy.Kind()] = make(map[reflect.Type]Adapter)
+	specificAdapterMap[rTypLazy.Kind()][rTypLazy] = LazyAdapter()
+	specificAdapterMap[rTypLoad.Kind()] = make(map[reflect.Type]Adapter)
+	specificAdapterMap[rTypLoad.Kind()][rTypLoad] = LoadAdapter()
+
 	// Third party adapters
 	specificAdapterMap[rTypUUID.Kind()] = make(map[reflect.Type]Adapter)
 	specificAdapterMap[rTypUUID.Kind()][rTypUUID] = UUIDAdapter()
 }
 
+// registryGeneration is incremented whenever an adapter is registered.
+//
+// The adapter maps are shared between registries, so any registration
+// invalidates the cached adapters of every registry.
+var registryGeneration atomic.Uint64
+
 type AdapterRegistry struct {
 	adapters map[reflect.Kind]map[reflect.Type]Adapter
 	defaults map[reflect.Kind]Adapter
 	iFaces   map[reflect.Type]Adapter
+	cache    atomic.Pointer[adapterCache]
+}
+
+// adapterCache holds the resolved adapter for each type which has been looked up.
+//
+// The cache is replaced as a whole when its generation is outdated.
+type adapterCache struct {
+	generation uint64
+	types      sync.Map // reflect.Type -> *cachedAdapter
 }
 
+type cachedAdapter struct {
+	adapter Adapter
+	found   bool
+	getter  bool // the type implements AdapterGetter, which has to be checked per value
+}
+
+var rTypAdapterGetter = reflect.TypeOf((*AdapterGetter)(nil)).Elem()
+
 func NewAdapterRegistry() *AdapterRegistry {
 	return &AdapterRegistry{
 		adapters: specificAdapterMap,
@@ -112,16 +154,44 @@ func NewAdapterRegistry() *AdapterRegistry {
 	}
 }
 
+// Clone returns a registry with a copy of the adapters registered so far.
+//
+// Registering an adapter on the clone does not affect any other registry.
+func (r *AdapterRegistry) Clone() *AdapterRegistry {
+	var clone = &AdapterRegistry{
+		adapters: make(map[reflect.Kind]map[reflect.Type]Adapter, len(r.adapters)),
+		defaults: make(map[reflect.Kind]Adapter, len(r.defaults)),
+		iFaces:   make(map[reflect.Type]Adapter, len(r.iFaces)),
+	}
+
+	for k, adapters := range r.adapters {
+		clone.adapters[k] = make(map[reflect.Type]Adapter, len(adapters))
+		for t, a := range adapters {
+			clone.adapters[k][t] = a
+		}
+	}
+	for k, a := range r.defaults {
+		clone.defaults[k] = a
+	}
+	for t, a := range r.iFaces {
+		clone.iFaces[t] = a
+	}
+
+	return clone
+}
+
 func (r *AdapterRegistry) RegisterAdapter(k reflect.Kind, t reflect.Type, a Adapter) {
 	if _, ok := r.adapters[k]; !ok {
 		r.adapters[k] = make(map[reflect.Type]Adapter)
 	}
 
 	r.adapters[k][t] = a
+	registryGeneration.Add(1)
 }
 
 func (r *AdapterRegistry) RegisterDefaultAdapter(k reflect.Kind, a Adapter) {
 	r.defaults[k] = a
+	registryGeneration.Add(1)
 }
 
 func (r *AdapterRegistry) Context() *JSContext {
@@ -144,6 +214,7 @@ func (r *AdapterRegistry) RegisterInterfaceAdapter(a Adapter, i interface{}) {
 	}
 
 	r.iFaces[t] = a
+	registryGeneration.Add(1)
 }
 
 func (r *AdapterRegistry) Register(adapter any, forType ...interface{}) {
@@ -185,24 +256,71 @@ func (r *AdapterRegistry) Find(ctx context.Context, value interface{}) (Adapter,
 		return nil, false
 	}
 
-	if getter, ok := value.(AdapterGetter); ok {
-		var a = getter.Adapter(ctx)
+	var (
+		t      = reflect.TypeOf(value)
+		cache  = r.currentCache()
+		cached *cachedAdapter
+	)
+
+	if v, ok := cache.types.Load(t); ok {
+		cached = v.(*cachedAdapter)
+	} else {
+		var a, found = r.Resolve(t)
+		cached = &cachedAdapter{
+			adapter: a,
+			found:   found,
+			getter:  t.Implements(rTypAdapterGetter),
+		}
+		cache.types.Store(t, cached)
+	}
+
+	if cached.getter {
+		var a = value.(AdapterGetter).Adapter(ctx)
 		if a != nil {
 			return a, true
 		}
 	}
 
+	return cached.adapter, cached.found
+}
+
+// currentCache returns the cache for the current generation, an outdated cache is replaced by an empty one.
+//
+// An adapter resolved while another one is being registered is stored in the outdated cache,
+// which is thrown away on the next call.
+func (r *AdapterRegistry) currentCache() *adapterCache {
 	var (
-		v = reflect.ValueOf(value)
-		k = v.Kind()
+		cache      = r.cache.Load()
+		generation = registryGeneration.Load()
 	)
 
-	if k == reflect.Invalid {
+	if cache != nil && cache.generation == generation {
+		return cache
+	}
+
+	var newCache = &adapterCache{generation: generation}
+	if r.cache.CompareAndSwap(cache, newCache) {
+		return newCache
+	}
+
+	// Another goroutine replaced the cache in the meantime.
+	if cache = r.cache.Load(); cache.generation == generation {
+		return cache
+	}
+	return newCache
+}
+
+// Resolve looks up the adapter for the type without using the cache.
+//
+// Adapters returned by AdapterGetter values are not taken into account,
+// these can only be known for a specific value.
+func (r *AdapterRegistry) Resolve(t reflect.Type) (Adapter, bool) {
+	if t == nil {
 		return nil, false
 	}
 
 	var (
-		t     = v.Type()
+		k     = t.Kind()
 		iType reflect.Type
 		a     Adapter
 		ok    bool
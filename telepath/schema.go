@@ -0,0 +1,138 @@
+package telepath
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrInvalidArgs is wrapped by errors returned when arguments do not match their schema.
+var ErrInvalidArgs = errors.New("invalid JS arguments")
+
+// JSArg describes an argument passed to a JS constructor.
+//
+// Optional arguments may be nil or left out, as long as they are the last arguments.
+// If Fields is set the argument is a dict with the described keys instead of a value of Type.
+type JSArg struct {
+	Name     string
+	Type     reflect.Type
+	Optional bool
+	Fields   []JSArg
+}
+
+// Arg returns the description of an argument named name of type V.
+func Arg[V any](name string) JSArg {
+	return JSArg{
+		Name: name,
+		Type: reflect.TypeOf((*V)(nil)).Elem(),
+	}
+}
+
+// OptionalArg returns the description of an optional argument named name of type V.
+func OptionalArg[V any](name string) JSArg {
+	var arg = Arg[V](name)
+	arg.Optional = true
+	return arg
+}
+
+// ValidateArgs checks the arguments for a JS constructor against their schema.
+//
+// A nil Type accepts any value.
+func ValidateArgs(constructor string, schema []JSArg, values []interface{}) error {
+	if len(values) > len(schema) {
+		return fmt.Errorf("%w: %s expects at most %d arguments, got %d", ErrInvalidArgs, constructor, len(schema), len(values))
+	}
+
+	for i, arg := range schema {
+		if i >= len(values) {
+			if !arg.Optional {
+				return fmt.Errorf("%w: %s is missing required argument %q", ErrInvalidArgs, constructor, arg.Name)
+			}
+			continue
+		}
+
+		if err := arg.validate(values[i]); err != nil {
+			return wrapPathError(fmt.Errorf("%w: %s argument %q %v", ErrInvalidArgs, constructor, arg.Name, err), i)
+		}
+	}
+
+	return nil
+}
+
+func devMode(c Context) bool {
+	var valueCtx, ok = c.(*ValueContext)
+	return ok && valueCtx.ParentContext != nil && valueCtx.ParentContext.DevMode
+}
+
+func (a JSArg) validate(value interface{}) error {
+	if a.Fields != nil {
+		return a.validateFields(value)
+	}
+
+	if value == nil {
+		if a.Optional || a.Type == nil {
+			return nil
+		}
+		switch a.Type.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface, reflect.Func, reflect.Chan:
+			return nil
+		}
+		return fmt.Errorf("must be %v, got nil", a.Type)
+	}
+
+	if a.Type == nil {
+		return nil
+	}
+
+	if t := reflect.TypeOf(value); !t.AssignableTo(a.Type) {
+		return fmt.Errorf("must be %v, got %v", a.Type, t)
+	}
+
+	return nil
+}
+
+func (a JSArg) validateFields(value interface{}) error {
+	var rVal = reflect.ValueOf(value)
+	if rVal.Kind() != reflect.Map || rVal.Type().Key().Kind() != reflect.String {
+		if value == nil && a.Optional {
+			return nil
+		}
+		return fmt.Errorf("must be a dict, got %T", value)
+	}
+
+	var known = make(map[string]bool, len(a.Fields))
+	for _, field := range a.Fields {
+		known[field.Name] = true
+
+		var fieldVal = rVal.MapIndex(reflect.ValueOf(field.Name).Convert(rVal.Type().Key()))
+		if !fieldVal.IsValid() {
+			if !field.Optional {
+				return fmt.Errorf("is missing required field %q", field.Name)
+			}
+			continue
+		}
+
+		if err := field.validate(fieldVal.Interface()); err != nil {
+			return fmt.Errorf("field %q %v", field.Name, err)
+		}
+	}
+
+	var iter = rVal.MapRange()
+	for iter.Next() {
+		if key := iter.Key().String(); !known[key] {
+			return fmt.Errorf("has unknown field %q", key)
+		}
+	}
+
+	return nil
+}
+
+// ConstructorAdapter is implemented by adapters which pack values as a JS constructor call.
+//
+// args is nil if the adapter does not describe its arguments.
+type ConstructorAdapter interface {
+	Adapter
+	Constructor() (name string, args []JSArg)
+}
+
+var _ ConstructorAdapter = (*ObjectAdapter[any])(nil)
@@ -6,13 +6,19 @@ import (
 )
 
 func PackJSON(ctx context.Context, context *JSContext, value interface{}) (string, error) {
-	newCtx := NewValueContext(context)
-	v, err := newCtx.BuildNode(ctx, value)
+	v, err := context.Pack(ctx, value)
 	if err != nil {
 		return "", err
 	}
-	b, err := json.Marshal(
-		v.Emit(),
-	)
+	b, err := json.Marshal(v)
 	return string(b), err
 }
+
+// PackEncoded packs the value and encodes it with the encoder, like CBOREncoding or MsgpackEncoding.
+func PackEncoded(ctx context.Context, context *JSContext, value interface{}, encoder Encoder) ([]byte, error) {
+	v, err := context.Pack(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	return encoder.Encode(v)
+}
@@ -0,0 +1,119 @@
+package telepath
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// OptionalTelepathAdapter packs values which might not be set, like the sql.Null types.
+//
+// GetValue returns the value to pack, null is packed if valid is false.
+type OptionalTelepathAdapter[T any] struct {
+	GetValue func(obj T) (value interface{}, valid bool)
+}
+
+func OptionalAdapter[T any](getValue func(obj T) (value interface{}, valid bool)) *OptionalTelepathAdapter[T] {
+	return &OptionalTelepathAdapter[T]{
+		GetValue: getValue,
+	}
+}
+
+func (m *OptionalTelepathAdapter[T]) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
+	var (
+		vt T
+		ok bool
+	)
+	if vt, ok = value.(T); !ok {
+		return nil, fmt.Errorf("value is not of type %T", vt)
+	}
+
+	var inner, valid = m.GetValue(vt)
+	if !valid {
+		return NullNode(), nil
+	}
+
+	return c.BuildNode(ctx, inner)
+}
+
+// ValuerTelepathAdapter packs values implementing driver.Valuer.
+//
+// Structs with a Valid bool and a V field (like sql.Null[T]) are packed as V or null,
+// any other value is packed as the result of its Value() method.
+type ValuerTelepathAdapter struct{}
+
+func ValuerAdapter() *ValuerTelepathAdapter {
+	return &ValuerTelepathAdapter{}
+}
+
+func (m *ValuerTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
+	var rVal = reflect.Indirect(reflect.ValueOf(value))
+	if rVal.Kind() == reflect.Struct {
+		var (
+			valid = rVal.FieldByName("Valid")
+			v     = rVal.FieldByName("V")
+		)
+		if valid.IsValid() && valid.Kind() == reflect.Bool && v.IsValid() && v.CanInterface() {
+			if !valid.Bool() {
+				return NullNode(), nil
+			}
+			return c.BuildNode(ctx, sqlTimeValue(v.Interface()))
+		}
+	}
+
+	var valuer, ok = value.(driver.Valuer)
+	if !ok {
+		return nil, fmt.Errorf("value does not implement driver.Valuer: %T", value)
+	}
+
+	var inner, err = valuer.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.BuildNode(ctx, sqlTimeValue(inner))
+}
+
+// sqlTimeValue formats a time.Time like encoding/json does, time.Time has no adapter by default.
+func sqlTimeValue(value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return value
+}
+
+// RegisterSQLAdapters registers adapters for the database/sql Null types.
+//
+// This also registers ValuerAdapter() for the driver.Valuer interface, which handles sql.Null[T];
+// types with a more specific adapter are not affected by it.
+func RegisterSQLAdapters(reg *AdapterRegistry) {
+	reg.Register(OptionalAdapter(func(v sql.NullString) (interface{}, bool) {
+		return v.String, v.Valid
+	}), sql.NullString{})
+	reg.Register(OptionalAdapter(func(v sql.NullInt64) (interface{}, bool) {
+		return v.Int64, v.Valid
+	}), sql.NullInt64{})
+	reg.Register(OptionalAdapter(func(v sql.NullInt32) (interface{}, bool) {
+		return v.Int32, v.Valid
+	}), sql.NullInt32{})
+	reg.Register(OptionalAdapter(func(v sql.NullInt16) (interface{}, bool) {
+		return v.Int16, v.Valid
+	}), sql.NullInt16{})
+	reg.Register(OptionalAdapter(func(v sql.NullByte) (interface{}, bool) {
+		return v.Byte, v.Valid
+	}), sql.NullByte{})
+	reg.Register(OptionalAdapter(func(v sql.NullFloat64) (interface{}, bool) {
+		return v.Float64, v.Valid
+	}), sql.NullFloat64{})
+	reg.Register(OptionalAdapter(func(v sql.NullBool) (interface{}, bool) {
+		return v.Bool, v.Valid
+	}), sql.NullBool{})
+	reg.Register(OptionalAdapter(func(v sql.NullTime) (interface{}, bool) {
+		return sqlTimeValue(v.Time), v.Valid
+	}), sql.NullTime{})
+
+	reg.RegisterInterfaceAdapter(ValuerAdapter(), (*driver.Valuer)(nil))
+}
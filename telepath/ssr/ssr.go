@@ -0,0 +1,240 @@
+// Package ssr renders packed telepath values to HTML on the server,
+// by unpacking them in a pool of goja JS runtimes and calling a render function.
+package ssr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"runtime"
+
+	"github.com/Nigel2392/go-telepath/telepath"
+	"github.com/dop251/goja"
+)
+
+const (
+	TELEPATH_JS_PATH    = "static/telepath/telepath.js"
+	DEFAULT_RENDER_FUNC = "render"
+)
+
+// Script is a JS script loaded into every runtime after the unpacker.
+//
+// Scripts are run as plain scripts, not as ES modules. They can register
+// constructors on the global TELEPATH and define the render function.
+type Script struct {
+	Name   string
+	Source string
+}
+
+type Options struct {
+	Scripts []Script
+
+	// RenderFunc is the name of the global JS function called with the unpacked value,
+	// it must return a string of HTML. Defaults to DEFAULT_RENDER_FUNC.
+	RenderFunc string
+
+	// PoolSize is the maximum amount of runtimes, defaults to runtime.GOMAXPROCS(0).
+	PoolSize int
+}
+
+// Renderer renders packed values using a pool of runtimes.
+//
+// Runtimes are created when needed, renders wait for a free runtime once PoolSize runtimes exist.
+type Renderer struct {
+	renderFunc string
+	programs   []*goja.Program
+	idle       chan *pooledRuntime
+	slots      chan struct{}
+}
+
+func New(opts Options) (*Renderer, error) {
+	var source, err = telepath.TelepathJS.ReadFile(TELEPATH_JS_PATH)
+	if err != nil {
+		return nil, err
+	}
+
+	var programs = make([]*goja.Program, 0, len(opts.Scripts)+2)
+	for _, script := range append([]Script{
+		{Name: TELEPATH_JS_PATH, Source: string(source)},
+		{Name: "telepath-init.js", Source: "var TELEPATH = new Telepath();"},
+	}, opts.Scripts...) {
+		var program, err = goja.Compile(script.Name, script.Source, false)
+		if err != nil {
+			return nil, fmt.Errorf("compiling %s: %w", script.Name, err)
+		}
+		programs = append(programs, program)
+	}
+
+	if opts.RenderFunc == "" {
+		opts.RenderFunc = DEFAULT_RENDER_FUNC
+	}
+
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = runtime.GOMAXPROCS(0)
+	}
+
+	var r = &Renderer{
+		renderFunc: opts.RenderFunc,
+		programs:   programs,
+		idle:       make(chan *pooledRuntime, opts.PoolSize),
+		slots:      make(chan struct{}, opts.PoolSize),
+	}
+
+	// Create the first runtime right away, so broken scripts are reported here.
+	vm, err := r.acquire(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	r.release(vm)
+
+	return r, nil
+}
+
+// RenderValue packs the value with the context and renders it.
+func (r *Renderer) RenderValue(ctx context.Context, jsContext *telepath.JSContext, value interface{}) (template.HTML, error) {
+	var packed, err = telepath.PackJSON(ctx, jsContext, value)
+	if err != nil {
+		return "", err
+	}
+	return r.Render(ctx, packed)
+}
+
+// Render unpacks the packed JSON and passes it to the render function.
+//
+// Rendering is interrupted when the context is done, in which case the
+// error wraps the context's error. Runtimes are discarded after any failed
+// render, globals added by a successful render are removed before the runtime is reused.
+func (r *Renderer) Render(ctx context.Context, packed string) (template.HTML, error) {
+	var vm, err = r.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var stop = context.AfterFunc(ctx, func() {
+		vm.Interrupt(ctx.Err())
+	})
+
+	html, err := r.render(vm.Runtime, packed)
+
+	if !stop() {
+		// The runtime might have been interrupted, its state can not be trusted anymore.
+		r.discard()
+		var interrupted *goja.InterruptedError
+		if errors.As(err, &interrupted) {
+			return "", fmt.Errorf("rendering interrupted: %w", ctx.Err())
+		}
+		return html, err
+	}
+
+	if err != nil || !vm.reset() {
+		r.discard()
+		return html, err
+	}
+
+	r.release(vm)
+	return html, nil
+}
+
+func (r *Renderer) render(vm *goja.Runtime, packed string) (template.HTML, error) {
+	var render, ok = goja.AssertFunction(vm.Get(r.renderFunc))
+	if !ok {
+		return "", fmt.Errorf("render function %q is not defined", r.renderFunc)
+	}
+
+	var jsonObj = vm.Get("JSON").ToObject(vm)
+	var parse, _ = goja.AssertFunction(jsonObj.Get("parse"))
+	var data, err = parse(jsonObj, vm.ToValue(packed))
+	if err != nil {
+		return "", fmt.Errorf("parsing packed value: %w", err)
+	}
+
+	var telepathObj = vm.Get("TELEPATH").ToObject(vm)
+	var unpack, _ = goja.AssertFunction(telepathObj.Get("unpack"))
+	value, err := unpack(telepathObj, data)
+	if err != nil {
+		return "", fmt.Errorf("unpacking: %w", err)
+	}
+
+	result, err := render(goja.Undefined(), value)
+	if err != nil {
+		return "", fmt.Errorf("rendering: %w", err)
+	}
+
+	var html, isString = result.Export().(string)
+	if !isString {
+		return "", fmt.Errorf("render function returned %v, expected a string", result)
+	}
+
+	return template.HTML(html), nil
+}
+
+// pooledRuntime is a runtime with the globals it had after loading the scripts.
+type pooledRuntime struct {
+	*goja.Runtime
+	globals map[string]goja.Value
+}
+
+// reset removes or restores the globals changed by a render,
+// it reports false if that was not possible.
+func (vm *pooledRuntime) reset() bool {
+	var global = vm.GlobalObject()
+	for _, key := range global.Keys() {
+		if _, ok := vm.globals[key]; !ok && global.Delete(key) != nil {
+			return false
+		}
+	}
+	for key, value := range vm.globals {
+		if !global.Get(key).SameAs(value) && global.Set(key, value) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Renderer) acquire(ctx context.Context) (*pooledRuntime, error) {
+	select {
+	case vm := <-r.idle:
+		return vm, nil
+	default:
+	}
+
+	select {
+	case vm := <-r.idle:
+		return vm, nil
+	case r.slots <- struct{}{}:
+		var vm, err = r.newRuntime()
+		if err != nil {
+			<-r.slots
+			return nil, err
+		}
+		return vm, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *Renderer) release(vm *pooledRuntime) {
+	r.idle <- vm
+}
+
+// discard frees the slot of a runtime which will not be reused.
+func (r *Renderer) discard() {
+	<-r.slots
+}
+
+func (r *Renderer) newRuntime() (*pooledRuntime, error) {
+	var vm = goja.New()
+	for _, program := range r.programs {
+		if _, err := vm.RunProgram(program); err != nil {
+			return nil, fmt.Errorf("loading scripts: %w", err)
+		}
+	}
+
+	var global = vm.GlobalObject()
+	var globals = make(map[string]goja.Value)
+	for _, key := range global.Keys() {
+		globals[key] = global.Get(key)
+	}
+	return &pooledRuntime{Runtime: vm, globals: globals}, nil
+}
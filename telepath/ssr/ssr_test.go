@@ -0,0 +1,169 @@
+package ssr_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Nigel2392/go-telepath/telepath"
+	"github.com/Nigel2392/go-telepath/telepath/ssr"
+)
+
+type Album struct {
+	Name    string
+	Artists []*Artist
+}
+
+type Artist struct {
+	Name string
+}
+
+const script = `
+class Album {
+	constructor(name, artists) {
+		this.name = name;
+		this.artists = artists;
+	}
+}
+
+class Artist {
+	constructor(name) {
+		this.name = name;
+	}
+}
+
+TELEPATH.register("ssr.Album", Album);
+TELEPATH.register("ssr.Artist", Artist);
+
+function render(album) {
+	if (album.name === "Forever") {
+		while (true) {}
+	}
+	return "<h1>" + album.name + "</h1><ul>" + album.artists.map(a => "<li>" + a.name + "</li>").join("") + "</ul>";
+}
+`
+
+func init() {
+	telepath.Register(&telepath.ObjectAdapter[*Album]{
+		JSConstructor: "ssr.Album",
+		GetJSArgs: func(obj *Album) []interface{} {
+			return []interface{}{obj.Name, obj.Artists}
+		},
+	}, &Album{})
+	telepath.Register(&telepath.ObjectAdapter[*Artist]{
+		JSConstructor: "ssr.Artist",
+		GetJSArgs: func(obj *Artist) []interface{} {
+			return []interface{}{obj.Name}
+		},
+	}, &Artist{})
+}
+
+func TestRender(t *testing.T) {
+	var renderer, err = ssr.New(ssr.Options{
+		Scripts:  []ssr.Script{{Name: "album.js", Source: script}},
+		PoolSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var album = &Album{Name: "Album", Artists: []*Artist{{Name: "Artist 1"}, {Name: "Artist 2"}}}
+	var expected = "<h1>Album</h1><ul><li>Artist 1</li><li>Artist 2</li></ul>"
+
+	html, err := renderer.RenderValue(context.Background(), telepath.NewContext(), album)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	if string(html) != expected {
+		t.Errorf("Expected %v, got %v", expected, html)
+	}
+
+	t.Run("TestConcurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var html, err = renderer.RenderValue(context.Background(), telepath.NewContext(), album)
+				if err != nil || string(html) != expected {
+					t.Errorf("Expected %v, got %v (%v)", expected, html, err)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("TestTimeout", func(t *testing.T) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		var _, err = renderer.RenderValue(ctx, telepath.NewContext(), &Album{Name: "Forever"})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+		}
+
+		// The interrupted runtime is replaced.
+		html, err := renderer.RenderValue(context.Background(), telepath.NewContext(), album)
+		if err != nil || string(html) != expected {
+			t.Errorf("Expected %v, got %v (%v)", expected, html, err)
+		}
+	})
+
+	t.Run("TestUnknownConstructor", func(t *testing.T) {
+		var _, err = renderer.Render(context.Background(), `{"_type":"ssr.Unknown","_args":[]}`)
+		if err == nil || !strings.Contains(err.Error(), "ssr.Unknown") {
+			t.Errorf("Expected unknown constructor error, got %v", err)
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	var _, err = ssr.New(ssr.Options{
+		Scripts: []ssr.Script{{Name: "broken.js", Source: `throw new Error("broken")`}},
+	})
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+}
+
+const leakingScript = `
+var TITLE = "title";
+
+function render(value) {
+	count = (typeof count === "undefined" ? 0 : count) + 1;
+	var title = TITLE;
+	TITLE = "changed";
+	if (value === "fail") {
+		throw new Error("failed");
+	}
+	return title + " " + count;
+}
+`
+
+func TestRenderIsolated(t *testing.T) {
+	var renderer, err = ssr.New(ssr.Options{
+		Scripts:  []ssr.Script{{Name: "leaking.js", Source: leakingScript}},
+		PoolSize: 1,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, packed := range []string{`"ok"`, `"ok"`, `"fail"`, `"ok"`} {
+		var html, err = renderer.Render(context.Background(), packed)
+		if packed == `"fail"` {
+			if err == nil {
+				t.Errorf("Expected error, got %v", html)
+			}
+			continue
+		}
+		if err != nil || html != "title 1" {
+			t.Errorf("Expected title 1, got %v (%v)", html, err)
+		}
+	}
+}
@@ -1,10 +1,40 @@
+type ValidationErrorMessage = {
+    message: string;
+    code: string;
+    params: {
+        [key: string]: any;
+    };
+};
+declare class ValidationError {
+    errors: ValidationErrorMessage[];
+    fieldErrors: {
+        [field: string]: ValidationError;
+    };
+    blockErrors: {
+        [index: string]: ValidationError;
+    };
+    constructor(errors: ValidationErrorMessage[], fieldErrors: {
+        [field: string]: ValidationError;
+    }, blockErrors: {
+        [index: string]: ValidationError;
+    });
+    get messages(): string[];
+    toString(): string;
+}
 declare class Telepath {
+    static ValidationError: typeof ValidationError;
     constructors: {
         [key: string]: any;
     };
+    factories: {
+        [key: string]: any;
+    };
     constructor();
     register(name: any, constructor: any): void;
+    registerFactory(name: any, factory: any): void;
     unpack(objData: any): any;
+    expandFormat(objData: any): any;
+    expandCompact(objData: any, types: string[]): any;
     scanForIds(objData: any, packedValuesById: {
         [key: number]: any;
     }): void;
@@ -2,6 +2,7 @@ package telepath
 
 import (
 	"context"
+	"encoding/json"
 	"html/template"
 
 	"golang.org/x/exp/constraints"
@@ -12,9 +13,13 @@ import (
 //go:embed static/telepath/telepath.js
 var TelepathJS embed.FS
 
+// DICT_RESERVED_KEYS make a dict get packed in a _dict, it must stay sorted.
+//
+// _format is reserved so a dict at the root is not mistaken for a format envelope.
 var DICT_RESERVED_KEYS = []string{
 	"_args",
 	"_dict",
+	"_format",
 	"_id",
 	"_list",
 	"_ref",
@@ -22,7 +27,12 @@ var DICT_RESERVED_KEYS = []string{
 	"_val",
 }
 
-const STRING_REF_MIN_LENGTH = 20 // Strings shorter than this will not be turned into references
+const (
+	STRING_REF_MIN_LENGTH  = 20      // Strings shorter than this will not be turned into references
+	CONTEXT_CHECK_INTERVAL = 128     // Check the context for cancellation every N nodes
+	PARALLEL_MIN_ITEMS     = 64      // Slices and maps with less items than this are always built sequentially
+	ITER_MAX_ITEMS         = 100_000 // Iterators and channels yielding more items than this fail to pack
+)
 
 type TelepathValue struct {
 	Type string                 `json:"_type,omitempty"`
@@ -34,6 +44,45 @@ type TelepathValue struct {
 	ID   int                    `json:"_id,omitempty"`
 }
 
+// MarshalJSON leaves out the keys which are not set.
+//
+// Unlike omitempty, empty arguments, dicts and lists are kept,
+// objects always have their _args.
+func (v TelepathValue) MarshalJSON() ([]byte, error) {
+	var value = struct {
+		Type string                  `json:"_type,omitempty"`
+		Args *[]any                  `json:"_args,omitempty"`
+		Dict *map[string]interface{} `json:"_dict,omitempty"`
+		List *[]interface{}          `json:"_list,omitempty"`
+		Val  interface{}             `json:"_val,omitempty"`
+		Ref  int                     `json:"_ref,omitempty"`
+		ID   int                     `json:"_id,omitempty"`
+	}{
+		Type: v.Type,
+		Val:  v.Val,
+		Ref:  v.Ref,
+		ID:   v.ID,
+	}
+
+	if v.Args != nil || v.Type != "" {
+		var args = v.Args
+		if args == nil {
+			args = []any{}
+		}
+		value.Args = &args
+	}
+
+	if v.Dict != nil {
+		value.Dict = &v.Dict
+	}
+
+	if v.List != nil {
+		value.List = &v.List
+	}
+
+	return json.Marshal(value)
+}
+
 type AdapterGetter interface {
 	Adapter(ctx context.Context) Adapter
 }
@@ -69,6 +118,12 @@ type Node interface {
 	GetID() int
 }
 
+// A ContainerNode holds other nodes, Children() must return them in a deterministic order.
+type ContainerNode interface {
+	Node
+	Children() []Node
+}
+
 type PrimitiveNodeValue interface {
 	constraints.Integer | constraints.Float | bool
 }
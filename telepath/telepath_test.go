@@ -2,13 +2,26 @@ package telepath_test
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	_ "embed"
 
 	"github.com/Nigel2392/go-telepath/telepath"
+	"github.com/Nigel2392/go-telepath/telepath/telepathtest"
 	"github.com/dop251/goja"
 	"github.com/google/uuid"
 )
@@ -18,6 +31,10 @@ var AlbumAdapter = &telepath.ObjectAdapter[*Album]{
 	GetJSArgs: func(obj *Album) []interface{} {
 		return []interface{}{obj.Name, obj.Artists}
 	},
+	Args: []telepath.JSArg{
+		telepath.Arg[string]("name"),
+		telepath.Arg[[]*Artist]("artists"),
+	},
 }
 
 var ArtistAdapter = &telepath.ObjectAdapter[*Artist]{
@@ -728,3 +745,2641 @@ func TestPackUUID(t *testing.T) {
 		t.Errorf("Expected %v, got %v", uuid, result)
 	}
 }
+
+type Cancelling struct {
+	Cancel context.CancelFunc
+}
+
+var CancellingAdapter = &telepath.ObjectAdapter[*Cancelling]{
+	JSConstructor: "js.funcs.Cancelling",
+	GetJSArgs: func(obj *Cancelling) []interface{} {
+		obj.Cancel()
+		return []interface{}{}
+	},
+}
+
+type Counted struct {
+	N int
+}
+
+// CountingAdapter is a custom adapter which counts how often it is called.
+type CountingAdapter struct {
+	Calls int
+}
+
+func (m *CountingAdapter) BuildNode(ctx context.Context, value any, c telepath.Context) (telepath.Node, error) {
+	m.Calls++
+	return telepath.NewPrimitiveNode(value.(Counted).N), nil
+}
+
+func TestPackCancelled(t *testing.T) {
+	telepath.Register(CancellingAdapter, &Cancelling{})
+
+	t.Run("TestAlreadyCancelled", func(t *testing.T) {
+		var cancelCtx, cancel = context.WithCancel(context.Background())
+		cancel()
+
+		var ctx = telepath.NewContext()
+		var _, err = ctx.Pack(cancelCtx, []int{1, 2, 3})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("TestCancelledWhilePacking", func(t *testing.T) {
+		var cancelCtx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+
+		var value = map[string]interface{}{
+			"albums": []interface{}{
+				&Cancelling{Cancel: cancel},
+				&Album{Name: "Hello"},
+			},
+		}
+
+		var ctx = telepath.NewContext()
+		var _, err = ctx.Pack(cancelCtx, value)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+			return
+		}
+
+		var pathErr *telepath.PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("Expected *telepath.PathError, got %T", err)
+			return
+		}
+
+		if pathErr.PathString() != "$.albums[1]" {
+			t.Errorf("Expected $.albums[1], got %v", pathErr.PathString())
+		}
+	})
+
+	t.Run("TestCustomAdapterNotCalled", func(t *testing.T) {
+		var cancelCtx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+
+		var adapter = &CountingAdapter{}
+		telepath.Register(adapter, Counted{})
+
+		var _, err = telepath.NewContext().Pack(cancelCtx, []interface{}{
+			&Cancelling{Cancel: cancel},
+			Counted{N: 1},
+		})
+
+		var pathErr *telepath.PathError
+		if !errors.As(err, &pathErr) || !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected *telepath.PathError wrapping %v, got %v", context.Canceled, err)
+			return
+		}
+
+		if pathErr.PathString() != "$[1]" {
+			t.Errorf("Expected $[1], got %v", pathErr.PathString())
+		}
+
+		if adapter.Calls != 0 {
+			t.Errorf("Expected adapter not to be called, got %d calls", adapter.Calls)
+		}
+	})
+
+	t.Run("TestCancelledWhilePackingPrimitives", func(t *testing.T) {
+		var cancelCtx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+
+		var value = []interface{}{&Cancelling{Cancel: cancel}}
+		for i := 0; i < telepath.CONTEXT_CHECK_INTERVAL*2; i++ {
+			value = append(value, i)
+		}
+
+		var _, err = telepath.NewContext().Pack(cancelCtx, value)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("TestDeadlineExceeded", func(t *testing.T) {
+		var deadlineCtx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		var _, err = telepath.PackJSON(deadlineCtx, telepath.NewContext(), "Hello")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
+
+func TestPackParallel(t *testing.T) {
+	telepath.Register(AlbumAdapter, &Album{})
+	telepath.Register(ArtistAdapter, &Artist{})
+
+	var (
+		artists = make([]*Artist, 10)
+		albums  = make([]*Album, 1000)
+		byName  = make(map[string]*Album)
+	)
+
+	for i := range artists {
+		artists[i] = &Artist{Name: fmt.Sprintf("Artist %d", i)}
+	}
+
+	for i := range albums {
+		albums[i] = &Album{
+			Name:    fmt.Sprintf("Album %d", i),
+			Artists: []*Artist{artists[i%len(artists)], artists[(i*7)%len(artists)]},
+		}
+		byName[albums[i].Name] = albums[i]
+	}
+
+	var value = []interface{}{albums, byName, albums[:10]}
+
+	var sequential = telepath.NewContext()
+	var expected, err = telepath.PackJSON(context.Background(), sequential, value)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	for i := 0; i < 5; i++ {
+		var parallel = telepath.NewContext()
+		parallel.Parallelism = 8
+		parallel.ParallelMinItems = 4
+
+		var result, err = telepath.PackJSON(context.Background(), parallel, value)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if result != expected {
+			t.Errorf("Expected parallel output to equal sequential output")
+			return
+		}
+	}
+
+	t.Run("TestParallelError", func(t *testing.T) {
+		var value = make([]interface{}, 100)
+		for i := range value {
+			value[i] = i
+		}
+		value[42] = func() {}
+
+		var ctx = telepath.NewContext()
+		ctx.Parallelism = 4
+
+		var _, err = ctx.Pack(context.Background(), value)
+		var pathErr *telepath.PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("Expected *telepath.PathError, got %v", err)
+			return
+		}
+
+		if pathErr.PathString() != "$[42]" {
+			t.Errorf("Expected $[42], got %v", pathErr.PathString())
+		}
+	})
+}
+
+type LazyDetails struct {
+	Name     string
+	Details  *telepath.LazyValue
+	Expanded bool
+}
+
+var LazyDetailsAdapter = &telepath.ObjectAdapter[*LazyDetails]{
+	JSConstructor: "js.funcs.LazyDetails",
+	GetJSArgs: func(obj *LazyDetails) []interface{} {
+		if !obj.Expanded {
+			return []interface{}{obj.Name}
+		}
+		return []interface{}{obj.Name, obj.Details}
+	},
+}
+
+func TestPackLazy(t *testing.T) {
+	telepath.Register(AlbumAdapter, &Album{})
+	telepath.Register(ArtistAdapter, &Artist{})
+	telepath.Register(LazyDetailsAdapter, &LazyDetails{})
+
+	t.Run("TestUnusedNotResolved", func(t *testing.T) {
+		var calls int
+		var details = telepath.Lazy(func(ctx context.Context) (interface{}, error) {
+			calls++
+			return "details", nil
+		})
+
+		var packed, err = telepath.PackJSON(context.Background(), telepath.NewContext(), []interface{}{
+			&LazyDetails{Name: "collapsed", Details: details},
+		})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if calls != 0 {
+			t.Errorf("Expected lazy value not to be resolved, got %d calls (%v)", calls, packed)
+		}
+
+		_, err = telepath.PackJSON(context.Background(), telepath.NewContext(), []interface{}{
+			&LazyDetails{Name: "collapsed", Details: details},
+			&LazyDetails{Name: "expanded", Details: details, Expanded: true},
+		})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if calls != 1 {
+			t.Errorf("Expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("TestResolvedOnceParallel", func(t *testing.T) {
+		var calls atomic.Int64
+		var lazy = telepath.Lazy(func(ctx context.Context) (interface{}, error) {
+			calls.Add(1)
+			return &Artist{Name: "Lazy Artist"}, nil
+		})
+
+		var value = make([]interface{}, 256)
+		for i := range value {
+			value[i] = lazy
+		}
+
+		var ctx = telepath.NewContext()
+		ctx.Parallelism = 8
+		ctx.ParallelMinItems = 1
+		var packed, err = telepath.PackJSON(context.Background(), ctx, value)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if n := calls.Load(); n != 1 {
+			t.Errorf("Expected 1 call, got %d", n)
+		}
+
+		if n := strings.Count(packed, `"_ref":1`); n != len(value)-1 {
+			t.Errorf("Expected %d references, got %d", len(value)-1, n)
+		}
+	})
+
+	t.Run("TestResolvedOnce", func(t *testing.T) {
+		var calls int
+		var lazy = telepath.Lazy(func(ctx context.Context) (interface{}, error) {
+			calls++
+			return &Artist{Name: "Lazy Artist"}, nil
+		})
+
+		var ctx = telepath.NewContext()
+		var result, err = ctx.Pack(context.Background(), []interface{}{lazy, lazy})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if calls != 1 {
+			t.Errorf("Expected 1, got %v", calls)
+		}
+
+		var chk = result.(telepath.TelepathValue)
+		var first = chk.List[0].(telepath.TelepathValue)
+		var second = chk.List[1].(telepath.TelepathValue)
+
+		if first.Type != "js.funcs.Artist" {
+			t.Errorf("Expected js.funcs.Artist, got %v", first.Type)
+		}
+
+		if first.ID != 1 {
+			t.Errorf("Expected 1, got %v", first.ID)
+		}
+
+		if second.Ref != 1 {
+			t.Errorf("Expected 1, got %v", second.Ref)
+		}
+	})
+
+	t.Run("TestSharesRefsWithValue", func(t *testing.T) {
+		var artist = &Artist{Name: "Artist"}
+		var lazy = telepath.Lazy(func(ctx context.Context) (interface{}, error) {
+			return artist, nil
+		})
+
+		var ctx = telepath.NewContext()
+		var result, err = ctx.Pack(context.Background(), []interface{}{artist, lazy})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		var chk = result.(telepath.TelepathValue)
+		if chk.List[1].(telepath.TelepathValue).Ref != 1 {
+			t.Errorf("Expected 1, got %v", chk.List[1].(telepath.TelepathValue).Ref)
+		}
+	})
+
+	t.Run("TestError", func(t *testing.T) {
+		var lazyErr = errors.New("lazy error")
+		var lazy = telepath.Lazy(func(ctx context.Context) (interface{}, error) {
+			return nil, lazyErr
+		})
+
+		var ctx = telepath.NewContext()
+		var _, err = ctx.Pack(context.Background(), map[string]interface{}{
+			"lazy": lazy,
+		})
+		if !errors.Is(err, lazyErr) {
+			t.Errorf("Expected %v, got %v", lazyErr, err)
+			return
+		}
+
+		if err.Error() != "$.lazy: lazy error" {
+			t.Errorf("Expected $.lazy: lazy error, got %v", err)
+		}
+	})
+}
+
+type Track struct {
+	Title    string
+	ArtistID int
+}
+
+var TrackAdapter = &telepath.ObjectAdapter[*Track]{
+	JSConstructor: "js.funcs.Track",
+	GetJSArgs: func(obj *Track) []interface{} {
+		return []interface{}{obj.Title, telepath.Load("artists", obj.ArtistID)}
+	},
+}
+
+func TestPackBatchLoad(t *testing.T) {
+	telepath.Register(TrackAdapter, &Track{})
+	telepath.Register(ArtistAdapter, &Artist{})
+
+	var artists = map[int]*Artist{
+		1: {Name: "Artist 1"},
+		2: {Name: "Artist 2"},
+	}
+
+	var calls [][]interface{}
+	var loadArtists = func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+		calls = append(calls, keys)
+		var values = make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = artists[key.(int)]
+		}
+		return values, nil
+	}
+
+	var tracks = []*Track{
+		{Title: "Track 1", ArtistID: 1},
+		{Title: "Track 2", ArtistID: 2},
+		{Title: "Track 3", ArtistID: 1},
+	}
+
+	var ctx = telepath.NewContext()
+	ctx.AddLoader("artists", loadArtists)
+
+	var result, err = ctx.Pack(context.Background(), tracks)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	if len(calls) != 1 {
+		t.Errorf("Expected 1, got %v", len(calls))
+		return
+	}
+
+	if len(calls[0]) != 2 || calls[0][0] != 1 || calls[0][1] != 2 {
+		t.Errorf("Expected [1 2], got %v", calls[0])
+	}
+
+	var chk = result.(telepath.TelepathValue)
+	var artist1 = chk.List[0].(telepath.TelepathValue).Args[1].(telepath.TelepathValue)
+	var artist2 = chk.List[1].(telepath.TelepathValue).Args[1].(telepath.TelepathValue)
+	var artist3 = chk.List[2].(telepath.TelepathValue).Args[1].(telepath.TelepathValue)
+
+	if artist1.Type != "js.funcs.Artist" || artist1.Args[0] != "Artist 1" {
+		t.Errorf("Expected js.funcs.Artist(Artist 1), got %v(%v)", artist1.Type, artist1.Args)
+	}
+
+	if artist1.ID != 1 {
+		t.Errorf("Expected 1, got %v", artist1.ID)
+	}
+
+	if artist2.Args[0] != "Artist 2" {
+		t.Errorf("Expected Artist 2, got %v", artist2.Args[0])
+	}
+
+	if artist3.Ref != 1 {
+		t.Errorf("Expected 1, got %v", artist3.Ref)
+	}
+
+	t.Run("TestNestedLoad", func(t *testing.T) {
+		var rounds int
+		var ctx = telepath.NewContext()
+		ctx.AddLoader("nested", func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+			rounds++
+			var values = make([]interface{}, len(keys))
+			for i, key := range keys {
+				if key.(int) > 0 {
+					values[i] = []interface{}{fmt.Sprint(key), telepath.Load("nested", key.(int)-1)}
+				}
+			}
+			return values, nil
+		})
+
+		var result, err = telepath.PackJSON(context.Background(), ctx, telepath.Load("nested", 2))
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if rounds != 3 {
+			t.Errorf("Expected 3, got %v", rounds)
+		}
+
+		if result != `{"_list":["2",{"_list":["1",null]}]}` {
+			t.Errorf("Expected nested lists, got %v", result)
+		}
+	})
+
+	t.Run("TestUnknownLoader", func(t *testing.T) {
+		var _, err = telepath.NewContext().Pack(context.Background(), []interface{}{
+			telepath.Load("unknown", 1),
+		})
+		if err == nil || err.Error() != `$[0]: no loader registered with name "unknown"` {
+			t.Errorf("Expected unknown loader error, got %v", err)
+		}
+	})
+
+	t.Run("TestUncomparableKey", func(t *testing.T) {
+		var ctx = telepath.NewContext()
+		ctx.AddLoader("artists", func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+			return keys, nil
+		})
+
+		var _, err = ctx.Pack(context.Background(), []interface{}{
+			telepath.Load("artists", []int{1, 2}),
+		})
+		if err == nil || err.Error() != `$[0]: key for loader "artists" is not comparable: []int` {
+			t.Errorf("Expected uncomparable key error, got %v", err)
+		}
+	})
+
+	t.Run("TestErrorPath", func(t *testing.T) {
+		var loaderErr = errors.New("loader error")
+		var ctx = telepath.NewContext()
+		ctx.AddLoader("artists", func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+			return nil, loaderErr
+		})
+
+		var _, err = ctx.Pack(context.Background(), map[string]interface{}{
+			"tracks": []*Track{{Title: "Track", ArtistID: 1}},
+		})
+		if !errors.Is(err, loaderErr) {
+			t.Errorf("Expected %v, got %v", loaderErr, err)
+			return
+		}
+
+		if err.Error() != `$.tracks[0]._args[1]: loader "artists": loader error` {
+			t.Errorf("Expected error at $.tracks[0]._args[1], got %v", err)
+		}
+
+		ctx.AddLoader("artists", func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+			return []interface{}{map[string]interface{}{"name": func() {}}}, nil
+		})
+
+		_, err = ctx.Pack(context.Background(), map[string]interface{}{
+			"tracks": []*Track{{Title: "Track", ArtistID: 1}},
+		})
+
+		var pathErr *telepath.PathError
+		if !errors.As(err, &pathErr) || pathErr.PathString() != "$.tracks[0]._args[1].name" {
+			t.Errorf("Expected error at $.tracks[0]._args[1].name, got %v", err)
+		}
+	})
+}
+
+type Point struct {
+	X, Y int
+}
+
+var PointAdapter = &telepath.ObjectAdapter[Point]{
+	JSConstructor: "js.funcs.Point",
+	GetJSArgs: func(obj Point) []interface{} {
+		return []interface{}{obj.X, obj.Y}
+	},
+}
+
+func newPoints(n int) []Point {
+	var points = make([]Point, n)
+	for i := range points {
+		points[i] = Point{X: i, Y: -i}
+	}
+	return points
+}
+
+type Celsius int
+
+type Sizer interface {
+	Size() int
+}
+
+type Closer interface {
+	Close() error
+}
+
+type CachedValue int
+
+func TestAdapterCache(t *testing.T) {
+	var (
+		registry = telepath.GlobalRegistry.Clone()
+		ctx      = context.Background()
+	)
+
+	var adapter, ok = registry.Find(ctx, CachedValue(1))
+	if _, isBase := adapter.(*telepath.BaseTelepathAdapter); !ok || !isBase {
+		t.Errorf("Expected *telepath.BaseTelepathAdapter, got %T", adapter)
+	}
+
+	var custom = &CountingAdapter{}
+	registry.Register(custom, CachedValue(0))
+
+	if adapter, _ = registry.Find(ctx, CachedValue(1)); adapter != custom {
+		t.Errorf("Expected %T registered after the lookup, got %T", custom, adapter)
+	}
+
+	if adapter, _ = telepath.Find(ctx, CachedValue(1)); adapter == custom {
+		t.Errorf("Expected adapter registered on a clone not to be used by the global registry")
+	}
+}
+
+func BenchmarkAdapterLookup(b *testing.B) {
+	var registry = telepath.GlobalRegistry.Clone()
+	registry.Register(PointAdapter, Point{})
+
+	// Register some interfaces, the uncached lookup checks all of them
+	// for each value which has no adapter registered for its exact type.
+	registry.RegisterInterfaceAdapter(NamerAdapter, (*Namer)(nil))
+	registry.RegisterInterfaceAdapter(NamerAdapter, (*Sizer)(nil))
+	registry.RegisterInterfaceAdapter(NamerAdapter, (*Closer)(nil))
+
+	var (
+		ctx    = context.Background()
+		values = map[string][]interface{}{
+			"Specific":  make([]interface{}, 100_000),
+			"Interface": make([]interface{}, 100_000),
+			"Default":   make([]interface{}, 100_000),
+		}
+	)
+
+	for i, p := range newPoints(100_000) {
+		values["Specific"][i] = p
+		values["Interface"][i] = &iFaceStruct{name: "Hello"}
+		values["Default"][i] = Celsius(p.X)
+	}
+
+	for _, name := range []string{"Specific", "Interface", "Default"} {
+		var values = values[name]
+
+		b.Run(name+"/Resolve", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, v := range values {
+					if _, ok := registry.Resolve(reflect.TypeOf(v)); !ok {
+						b.Fatalf("Expected adapter for %T", v)
+					}
+				}
+			}
+		})
+
+		b.Run(name+"/Find", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, v := range values {
+					if _, ok := registry.Find(ctx, v); !ok {
+						b.Fatalf("Expected adapter for %T", v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPackStructs(b *testing.B) {
+	telepath.Register(PointAdapter, Point{})
+
+	var points = newPoints(100_000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var ctx = telepath.NewContext()
+		if _, err := ctx.Pack(context.Background(), points); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPackPrimitives(t *testing.T) {
+	var ints = []int{1, 2, 3}
+	var value = []interface{}{
+		42,
+		1.5,
+		true,
+		"Hello",
+		[]interface{}{1, false, 2.5, "World"},
+		ints,
+		[]float64{0.5, 1.5},
+		[]string{"a", "b"},
+		[]interface{}{ints},
+		[]int(nil),
+		[2]int{4, 5},
+	}
+
+	var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected = `{"_list":[42,1.5,true,"Hello",{"_list":[1,false,2.5,"World"]},` +
+		`{"_list":[1,2,3],"_id":1},{"_list":[0.5,1.5]},{"_list":["a","b"]},{"_list":[{"_ref":1}]},` +
+		`{"_list":[]},{"_list":[4,5]}]}`
+
+	if result != expected {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	t.Run("TestCustomAdapter", func(t *testing.T) {
+		// Registering on a clone keeps Celsius packed as a number everywhere else.
+		var registry = telepath.GlobalRegistry.Clone()
+		registry.Register(CelsiusAdapter, Celsius(0))
+
+		var result, err = telepath.PackJSON(context.Background(), registry.Context(), []Celsius{20})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if result != `{"_list":[{"_type":"js.funcs.Celsius","_args":[20]}]}` {
+			t.Errorf("Expected list of js.funcs.Celsius, got %v", result)
+		}
+	})
+}
+
+var CelsiusAdapter = &telepath.ObjectAdapter[Celsius]{
+	JSConstructor: "js.funcs.Celsius",
+	GetJSArgs: func(obj Celsius) []interface{} {
+		return []interface{}{int(obj)}
+	},
+}
+
+func BenchmarkPackPrimitives(b *testing.B) {
+	var (
+		ints       = make([]int, 10_000)
+		floats     = make([]float64, 10_000)
+		strs       = make([]string, 10_000)
+		interfaces = make([]interface{}, 10_000)
+	)
+
+	for i := range ints {
+		ints[i] = i * 1000
+		floats[i] = float64(i) / 3
+		strs[i] = fmt.Sprint(i)
+		interfaces[i] = i * 1000
+	}
+
+	var values = []struct {
+		name  string
+		value interface{}
+	}{
+		{"Ints", ints},
+		{"Floats", floats},
+		{"Strings", strs},
+		{"Interfaces", interfaces},
+	}
+
+	for _, v := range values {
+		var value = v.value
+		b.Run(v.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var ctx = telepath.NewContext()
+				if _, err := ctx.Pack(context.Background(), value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestValueContextPool(t *testing.T) {
+	telepath.Register(AlbumAdapter, &Album{})
+	telepath.Register(ArtistAdapter, &Artist{})
+
+	var artist = &Artist{Name: "Artist"}
+	var value = []interface{}{
+		&Album{Name: "Album", Artists: []*Artist{artist, artist}},
+		[]interface{}{1, 2, 3},
+	}
+
+	var jsCtx = telepath.NewContext()
+	var valueCtx = telepath.AcquireValueContext(jsCtx)
+	var node, err = valueCtx.BuildRoot(context.Background(), value)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected, _ = json.Marshal(node.Emit())
+	valueCtx.Release()
+
+	for i := 0; i < 10; i++ {
+		var valueCtx = telepath.AcquireValueContext(jsCtx)
+		if valueCtx.NextID != 0 || len(valueCtx.Nodes) != 0 || len(valueCtx.RawValues) != 0 {
+			t.Errorf("Expected released context to be reset, got %+v", valueCtx)
+			return
+		}
+
+		var node, err = valueCtx.BuildRoot(context.Background(), value)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		var emitted = node.Emit()
+		valueCtx.Release()
+
+		// Values which were emitted must not be affected by releasing the context.
+		var result, _ = json.Marshal(emitted)
+		if string(result) != string(expected) {
+			t.Errorf("Expected %s, got %s", expected, result)
+			return
+		}
+	}
+
+	t.Run("TestPackAfterRelease", func(t *testing.T) {
+		var valueCtx = telepath.AcquireValueContext(jsCtx)
+		var node, err = valueCtx.BuildRoot(context.Background(), value)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		var emitted = node.Emit()
+		valueCtx.Release()
+
+		// Reuse the released primitive nodes for different values.
+		for i := 0; i < 10; i++ {
+			if _, err := telepath.PackJSON(context.Background(), jsCtx, []interface{}{"other", 4, 5, 6, false}); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+		}
+
+		var result, _ = json.Marshal(emitted)
+		if string(result) != string(expected) {
+			t.Errorf("Expected %s, got %s", expected, result)
+		}
+	})
+}
+
+func BenchmarkValueContextPool(b *testing.B) {
+	telepath.Register(AlbumAdapter, &Album{})
+	telepath.Register(ArtistAdapter, &Artist{})
+
+	var numbers = make([]interface{}, 100)
+	for i := range numbers {
+		numbers[i] = i * 1000
+	}
+
+	var artist = &Artist{Name: "Artist"}
+	var value = []interface{}{
+		&Album{Name: "Album 1", Artists: []*Artist{artist}},
+		&Album{Name: "Album 2", Artists: []*Artist{artist}},
+		numbers,
+	}
+
+	var jsCtx = telepath.NewContext()
+	var run = func(b *testing.B, acquire func() *telepath.ValueContext, release func(*telepath.ValueContext)) {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var valueCtx = acquire()
+			var node, err = valueCtx.BuildRoot(context.Background(), value)
+			if err != nil {
+				b.Fatal(err)
+			}
+			node.Emit()
+			release(valueCtx)
+		}
+		b.StopTimer()
+
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N)*1000, "GCs/1000op")
+	}
+
+	b.Run("New", func(b *testing.B) {
+		run(b, func() *telepath.ValueContext {
+			return telepath.NewValueContext(jsCtx)
+		}, func(*telepath.ValueContext) {})
+	})
+
+	b.Run("Pooled", func(b *testing.B) {
+		run(b, func() *telepath.ValueContext {
+			return telepath.AcquireValueContext(jsCtx)
+		}, (*telepath.ValueContext).Release)
+	})
+}
+
+func TestPackPointers(t *testing.T) {
+	telepath.Register(AlbumAdapter, &Album{})
+	telepath.Register(ArtistAdapter, &Artist{})
+
+	var (
+		number   = 42
+		flag     = true
+		short    = "Hello"
+		long     = "This string is long enough to be referenced"
+		album    = &Album{Name: "Album", Artists: []*Artist{{Name: "Artist"}}}
+		albumPtr = &album
+		nilAlbum *Album
+		nilPtr   **Album = &nilAlbum
+		longPtr          = &long
+	)
+
+	var value = []interface{}{
+		&number,
+		&flag,
+		&short,
+		longPtr,
+		longPtr,
+		albumPtr,
+		album,
+		albumPtr,
+		nilAlbum,
+		nilPtr,
+		&longPtr,
+	}
+
+	var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected = `{"_list":[42,true,"Hello",` +
+		`{"_val":"This string is long enough to be referenced","_id":1},{"_ref":1},` +
+		`{"_type":"js.funcs.Album","_args":["Album",{"_list":[{"_type":"js.funcs.Artist","_args":["Artist"]}]}],"_id":2},` +
+		`{"_ref":2},{"_ref":2},` +
+		`null,null,{"_ref":1}]}`
+
+	if result != expected {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	t.Run("TestSharedShortStrings", func(t *testing.T) {
+		var (
+			short = "hi"
+			empty = ""
+			long  = "This string is long enough to be referenced"
+			value = []interface{}{&short, &short, &empty, &empty, &number, &number, &long, &long}
+		)
+
+		for _, parallelism := range []int{0, 4} {
+			var ctx = telepath.NewContext()
+			ctx.Parallelism = parallelism
+			ctx.ParallelMinItems = 1
+
+			var result, err = telepath.PackJSON(context.Background(), ctx, value)
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+
+			var expected = `{"_list":["hi","hi","","",42,42,` +
+				`{"_val":"This string is long enough to be referenced","_id":1},{"_ref":1}]}`
+
+			if result != expected {
+				t.Errorf("Expected %v, got %v", expected, result)
+			}
+		}
+	})
+
+	t.Run("TestNoAdapter", func(t *testing.T) {
+		var value = &struct{ Name string }{Name: "Hello"}
+		var _, err = telepath.PackJSON(context.Background(), telepath.NewContext(), &value)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+type Hash [4]byte
+
+func TestPackArrays(t *testing.T) {
+	telepath.Register(ArtistAdapter, &Artist{})
+	telepath.Register(PointAdapter, Point{})
+
+	var (
+		shared  = &[3]int{1, 2, 3}
+		artists = [2]*Artist{{Name: "Artist 1"}, {Name: "Artist 2"}}
+		points  = [2]Point{{X: 1, Y: 2}, {X: 3, Y: 4}}
+	)
+
+	var value = []interface{}{
+		[3]float64{0.5, 1, 1.5},
+		[2]byte{1, 2},
+		artists,
+		points,
+		shared,
+		shared,
+	}
+
+	var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected = `{"_list":[{"_list":[0.5,1,1.5]},{"_list":[1,2]},` +
+		`{"_list":[{"_type":"js.funcs.Artist","_args":["Artist 1"]},{"_type":"js.funcs.Artist","_args":["Artist 2"]}]},` +
+		`{"_list":[{"_type":"js.funcs.Point","_args":[1,2]},{"_type":"js.funcs.Point","_args":[3,4]}]},` +
+		`{"_list":[1,2,3],"_id":1},{"_ref":1}]}`
+
+	if result != expected {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	t.Run("TestByteEncoding", func(t *testing.T) {
+		var registry = telepath.GlobalRegistry.Clone()
+		var hash = Hash{0xde, 0xad, 0xbe, 0xef}
+
+		registry.Register(&telepath.ArrayTelepathAdapter{ByteEncoding: telepath.BYTE_ENCODING_HEX}, Hash{})
+		var result, err = telepath.PackJSON(context.Background(), registry.Context(), hash)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if result != `"deadbeef"` {
+			t.Errorf("Expected \"deadbeef\", got %v", result)
+		}
+
+		registry.Register(&telepath.ArrayTelepathAdapter{ByteEncoding: telepath.BYTE_ENCODING_BASE64}, Hash{})
+		result, err = telepath.PackJSON(context.Background(), registry.Context(), &hash)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if result != `"3q2+7w=="` {
+			t.Errorf("Expected \"3q2+7w==\", got %v", result)
+		}
+	})
+
+	t.Run("TestUnknownByteEncoding", func(t *testing.T) {
+		if _, err := telepath.BYTE_ENCODING_LIST.Encode([]byte{1}); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+
+		var registry = telepath.GlobalRegistry.Clone()
+		registry.Register(&telepath.ArrayTelepathAdapter{ByteEncoding: telepath.ByteEncoding(42)}, Hash{})
+		if _, err := telepath.PackJSON(context.Background(), registry.Context(), Hash{}); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+type Blob []byte
+
+func TestPackBytes(t *testing.T) {
+	var value = []interface{}{
+		[]byte{1, 2, 3},
+		json.RawMessage(`{"_type": "not a constructor", "list": [1, 2]}`),
+		json.RawMessage(`42`),
+		[]byte(nil),
+	}
+
+	var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected = `{"_list":["AQID",{"_val":{"_type":"not a constructor","list":[1,2]}},42,null]}`
+	if result != expected {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	t.Run("TestInvalidRawJSON", func(t *testing.T) {
+		var _, err = telepath.PackJSON(context.Background(), telepath.NewContext(), json.RawMessage(`{`))
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("TestUint8Array", func(t *testing.T) {
+		var registry = telepath.GlobalRegistry.Clone()
+		registry.Register(&telepath.BytesTelepathAdapter{Encoding: telepath.BYTE_ENCODING_UINT8ARRAY}, Blob{})
+
+		var blob = Blob{0, 127, 128, 255, 42}
+		var result, err = telepath.PackJSON(context.Background(), registry.Context(), []interface{}{blob, blob})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if !strings.Contains(result, `"_type":"telepath.Uint8Array"`) {
+			t.Errorf("Expected telepath.Uint8Array constructor, got %v", result)
+		}
+
+		var vm = goja.New()
+		if _, err = vm.RunString(telepath_js); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		vm.Set("testData", result)
+		var chk, _ = vm.RunString(`var data = TELEPATH.unpack(JSON.parse(testData));
+			data[0] instanceof Uint8Array && data[0] === data[1] && data[0].join(",")`)
+
+		if chk.String() != "0,127,128,255,42" {
+			t.Errorf("Expected 0,127,128,255,42, got %v (%v)", chk, result)
+		}
+	})
+}
+
+func TestPackSpecialNumbers(t *testing.T) {
+	var value = []interface{}{
+		math.NaN(),
+		math.Inf(1),
+		[]float64{1.5, math.Inf(-1)},
+		int64(1 << 60),
+		uint64(math.MaxUint64),
+		[]int64{1, -1 << 60},
+		int64(42),
+	}
+
+	t.Run("TestNone", func(t *testing.T) {
+		var _, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("TestNullAndString", func(t *testing.T) {
+		var ctx = telepath.NewContext()
+		ctx.FloatPolicy = telepath.FLOAT_POLICY_NULL
+		ctx.BigIntPolicy = telepath.BIGINT_POLICY_STRING
+
+		var result, err = telepath.PackJSON(context.Background(), ctx, value)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		var expected = `{"_list":[null,null,{"_list":[1.5,null]},"1152921504606846976",` +
+			`"18446744073709551615",{"_list":[1,"-1152921504606846976"]},42]}`
+		if result != expected {
+			t.Errorf("Expected %v, got %v", expected, result)
+		}
+	})
+
+	t.Run("TestMarkers", func(t *testing.T) {
+		var ctx = telepath.NewContext()
+		ctx.FloatPolicy = telepath.FLOAT_POLICY_MARKER
+		ctx.BigIntPolicy = telepath.BIGINT_POLICY_BIGINT
+
+		var result, err = telepath.PackJSON(context.Background(), ctx, value)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if !strings.Contains(result, `"_type":"telepath.Float"`) || !strings.Contains(result, `"_type":"telepath.BigInt"`) {
+			t.Errorf("Expected telepath.Float and telepath.BigInt constructors, got %v", result)
+		}
+
+		var vm = goja.New()
+		if _, err = vm.RunString(telepath_js); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		vm.Set("testData", result)
+		var chk, _ = vm.RunString(`var data = TELEPATH.unpack(JSON.parse(testData));
+			[isNaN(data[0]), data[1] === Infinity, data[2][1] === -Infinity, data[3], data[4], data[5][1], data[6]].join(",")`)
+
+		// goja has no BigInt, the bundled unpacker keeps these as strings
+		var expected = "true,true,true,1152921504606846976,18446744073709551615,-1152921504606846976,42"
+		if chk.String() != expected {
+			t.Errorf("Expected %v, got %v (%v)", expected, chk, result)
+		}
+	})
+}
+
+// Null mirrors the generic sql.Null[T] from Go 1.22
+type Null[T any] struct {
+	V     T
+	Valid bool
+}
+
+func (n Null[T]) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.V, nil
+}
+
+type SQLModel struct {
+	Name     sql.NullString
+	Count    sql.NullInt64
+	Small    sql.NullInt32
+	Score    sql.NullFloat64
+	Active   sql.NullBool
+	Created  sql.NullTime
+	Deleted  sql.NullTime
+	Generic  Null[string]
+	Missing  Null[int]
+	Optional *sql.NullString
+}
+
+var SQLModelAdapter = &telepath.ObjectAdapter[*SQLModel]{
+	JSConstructor: "js.funcs.SQLModel",
+	GetJSArgs: func(obj *SQLModel) []interface{} {
+		return []interface{}{
+			obj.Name, obj.Count, obj.Small, obj.Score, obj.Active,
+			obj.Created, obj.Deleted, obj.Generic, obj.Missing, obj.Optional,
+		}
+	},
+}
+
+func TestPackSQLNullTypes(t *testing.T) {
+	// The driver.Valuer adapter would affect every other test.
+	var registry = telepath.GlobalRegistry.Clone()
+	telepath.RegisterSQLAdapters(registry)
+	registry.Register(SQLModelAdapter, &SQLModel{})
+
+	var model = &SQLModel{
+		Name:    sql.NullString{String: "Hello", Valid: true},
+		Count:   sql.NullInt64{Int64: 42, Valid: true},
+		Small:   sql.NullInt32{},
+		Score:   sql.NullFloat64{Float64: 1.5, Valid: true},
+		Active:  sql.NullBool{Bool: false, Valid: true},
+		Created: sql.NullTime{Time: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), Valid: true},
+		Deleted: sql.NullTime{},
+		Generic: Null[string]{V: "Generic", Valid: true},
+		Missing: Null[int]{V: 1},
+	}
+
+	var result, err = telepath.PackJSON(context.Background(), registry.Context(), model)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected = `{"_type":"js.funcs.SQLModel","_args":["Hello",42,null,1.5,false,` +
+		`"2024-06-01T12:00:00Z",null,"Generic",null,null]}`
+	if result != expected {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	t.Run("TestNullTime", func(t *testing.T) {
+		var created = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		var values = []interface{}{
+			sql.NullTime{Time: created, Valid: true},
+			Null[time.Time]{V: created, Valid: true},
+			NullTimeValuer{Time: created},
+		}
+
+		var result, err = telepath.PackJSON(context.Background(), registry.Context(), values)
+		var expected = `{"_list":["2024-06-01T12:00:00Z","2024-06-01T12:00:00Z","2024-06-01T12:00:00Z"]}`
+		if err != nil || result != expected {
+			t.Errorf("Expected %v, got %v (%v)", expected, result, err)
+		}
+	})
+}
+
+// NullTimeValuer returns a time.Time from Value(), without a V field.
+type NullTimeValuer struct {
+	Time time.Time
+}
+
+func (n NullTimeValuer) Value() (driver.Value, error) {
+	return n.Time, nil
+}
+
+// Seq and Seq2 mirror iter.Seq and iter.Seq2.
+type Seq[V any] func(yield func(V) bool)
+
+type Seq2[K, V any] func(yield func(K, V) bool)
+
+func TestPackIterators(t *testing.T) {
+	var numbers Seq[int] = func(yield func(int) bool) {
+		for i := 1; i <= 3; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+
+	var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), numbers)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	if result != `{"_list":[1,2,3]}` {
+		t.Errorf("Expected %v, got %v", `{"_list":[1,2,3]}`, result)
+	}
+
+	t.Run("TestSeq2", func(t *testing.T) {
+		var pairs Seq2[string, string] = func(yield func(string, string) bool) {
+			_ = yield("a", "Hello") && yield("b", "World")
+		}
+
+		var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), []interface{}{pairs})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		var expected = `{"_list":[{"a":"Hello","b":"World"}]}`
+		if result != expected {
+			t.Errorf("Expected %v, got %v", expected, result)
+		}
+	})
+
+	t.Run("TestLimit", func(t *testing.T) {
+		var yielded int
+		var infinite Seq[int] = func(yield func(int) bool) {
+			for i := 0; yield(i); i++ {
+				yielded++
+			}
+		}
+
+		var jsContext = telepath.NewContext()
+		jsContext.MaxIterItems = 10
+
+		var _, err = telepath.PackJSON(context.Background(), jsContext, infinite)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+
+		if yielded != 10 {
+			t.Errorf("Expected %d items, got %d", 10, yielded)
+		}
+	})
+
+	t.Run("TestCancelled", func(t *testing.T) {
+		var ctx, cancel = context.WithCancel(context.Background())
+		var infinite Seq[int] = func(yield func(int) bool) {
+			for i := 0; yield(i); i++ {
+				if i == 5 {
+					cancel()
+				}
+			}
+		}
+
+		var _, err = telepath.PackJSON(ctx, telepath.NewContext(), infinite)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("TestError", func(t *testing.T) {
+		var funcs Seq2[string, interface{}] = func(yield func(string, interface{}) bool) {
+			_ = yield("ok", 1) && yield("bad", func() {})
+		}
+
+		var _, err = telepath.PackJSON(context.Background(), telepath.NewContext(), funcs)
+		var pathErr *telepath.PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("Expected PathError, got %v", err)
+			return
+		}
+
+		if pathErr.PathString() != "$.bad" {
+			t.Errorf("Expected %v, got %v", "$.bad", pathErr.PathString())
+		}
+	})
+
+	t.Run("TestChannel", func(t *testing.T) {
+		var ch = make(chan string, 3)
+		ch <- "a"
+		ch <- "b"
+		close(ch)
+
+		var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), ch)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if result != `{"_list":["a","b"]}` {
+			t.Errorf("Expected %v, got %v", `{"_list":["a","b"]}`, result)
+		}
+	})
+
+	t.Run("TestChannelTimeout", func(t *testing.T) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		var ch = make(chan int)
+		var _, err = telepath.PackJSON(ctx, telepath.NewContext(), ch)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	})
+}
+
+type Color int
+
+const (
+	Red Color = iota + 1
+	Green
+	Blue
+)
+
+func (c Color) String() string {
+	switch c {
+	case Red:
+		return "Red"
+	case Green:
+		return "Green"
+	case Blue:
+		return "Blue"
+	}
+	return fmt.Sprintf("Color(%d)", int(c))
+}
+
+func TestPackEnum(t *testing.T) {
+	var adapter = telepath.StringerEnumAdapter("Enum.Color", Red, Green, Blue)
+	var registry = telepath.GlobalRegistry.Clone()
+	registry.Register(adapter, Red)
+
+	var tests = []struct {
+		mode     telepath.EnumMode
+		expected string
+	}{
+		{telepath.ENUM_MODE_NAME, `{"_list":["Red","Blue"]}`},
+		{telepath.ENUM_MODE_CONSTANT, `{"_list":[{"_type":"Enum.Color","_args":["Red"]},{"_type":"Enum.Color","_args":["Blue"]}]}`},
+		{telepath.ENUM_MODE_VALUE, `{"_list":[1,3]}`},
+	}
+
+	for _, test := range tests {
+		adapter.Mode = test.mode
+
+		var result, err = telepath.PackJSON(context.Background(), registry.Context(), []Color{Red, Blue})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			continue
+		}
+
+		if result != test.expected {
+			t.Errorf("Expected %v, got %v", test.expected, result)
+		}
+	}
+
+	t.Run("TestUnknownValue", func(t *testing.T) {
+		adapter.Mode = telepath.ENUM_MODE_NAME
+
+		var _, err = telepath.PackJSON(context.Background(), registry.Context(), Color(42))
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("TestConstants", func(t *testing.T) {
+		adapter.Mode = telepath.ENUM_MODE_CONSTANT
+
+		var result, err = telepath.PackJSON(context.Background(), registry.Context(), []Color{Green, Green})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		constants, err := json.Marshal(adapter.Constants())
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		var vm = goja.New()
+		if _, err = vm.RunString(telepath_js); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		vm.Set("constants", string(constants))
+		vm.Set("testData", result)
+		var chk, _ = vm.RunString(`var Color = JSON.parse(constants);
+			TELEPATH.registerFactory("Enum.Color", function (name) { return Color[name]; });
+			TELEPATH.unpack(JSON.parse(testData)).join(",")`)
+
+		if chk.String() != "2,2" {
+			t.Errorf("Expected 2,2, got %v (%v)", chk, result)
+		}
+	})
+}
+
+func TestPackValidationError(t *testing.T) {
+	var value = fmt.Errorf("saving block: %w", errors.Join(
+		&telepath.ValidationError{
+			Message: "Ensure this value has at most 10 characters",
+			Code:    "max_length",
+			Params:  map[string]interface{}{"limit_value": 10},
+			Fields: map[string]error{
+				"title": errors.New("This field is required"),
+			},
+		},
+		&telepath.ValidationError{
+			Items: map[int]error{
+				2: &telepath.ValidationError{Message: "Invalid URL", Code: "invalid"},
+			},
+		},
+	))
+
+	var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected = `{"_type":"telepath.ValidationError","_args":[` +
+		`{"_list":[{"code":"max_length","message":"Ensure this value has at most 10 characters","params":{"limit_value":10}}]},` +
+		`{"title":{"_type":"telepath.ValidationError","_args":[{"_list":[{"code":"","message":"This field is required","params":{}}]},{},{}]}},` +
+		`{"2":{"_type":"telepath.ValidationError","_args":[{"_list":[{"code":"invalid","message":"Invalid URL","params":{}}]},{},{}]}}]}`
+
+	if result != expected {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	t.Run("TestPlainError", func(t *testing.T) {
+		var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), errors.New("Hello"))
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if result != `"Hello"` {
+			t.Errorf("Expected %v, got %v", `"Hello"`, result)
+		}
+	})
+
+	t.Run("TestError", func(t *testing.T) {
+		var expected = "Ensure this value has at most 10 characters; title: This field is required"
+		var err = &telepath.ValidationError{
+			Message: "Ensure this value has at most 10 characters",
+			Fields: map[string]error{
+				"title": errors.New("This field is required"),
+			},
+		}
+
+		if err.Error() != expected {
+			t.Errorf("Expected %v, got %v", expected, err.Error())
+		}
+	})
+
+	t.Run("TestUnpack", func(t *testing.T) {
+		var vm = goja.New()
+		if _, err = vm.RunString(telepath_js); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		vm.Set("testData", result)
+		var chk, _ = vm.RunString(`var error = TELEPATH.unpack(JSON.parse(testData));
+			error instanceof Telepath.ValidationError &&
+			error.fieldErrors.title instanceof Telepath.ValidationError &&
+			[error.errors[0].code, error.fieldErrors.title.toString(), error.blockErrors[2].messages[0]].join("|")`)
+
+		var expected = "max_length|This field is required|Invalid URL"
+		if chk.String() != expected {
+			t.Errorf("Expected %v, got %v (%v)", expected, chk, result)
+		}
+	})
+
+	t.Run("TestErrorPath", func(t *testing.T) {
+		var value = &telepath.ValidationError{
+			Fields: map[string]error{
+				"name": &telepath.ValidationError{
+					Message: "Invalid",
+					Params:  map[string]interface{}{"p": complex(1, 2)},
+				},
+			},
+			Items: map[int]error{
+				3: &telepath.ValidationError{
+					Message: "Invalid",
+					Params:  map[string]interface{}{"p": complex(1, 2)},
+				},
+			},
+		}
+
+		var _, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+		var pathErr *telepath.PathError
+		if !errors.As(err, &pathErr) || pathErr.PathString() != "$._args[1].name._args[0][0].params.p" {
+			t.Errorf("Expected $._args[1].name._args[0][0].params.p, got %v", err)
+		}
+
+		delete(value.Fields, "name")
+		_, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+		if !errors.As(err, &pathErr) || pathErr.PathString() != "$._args[2].3._args[0][0].params.p" {
+			t.Errorf("Expected $._args[2].3._args[0][0].params.p, got %v", err)
+		}
+	})
+
+	t.Run("TestStableIDs", func(t *testing.T) {
+		telepath.Register(ArtistAdapter, &Artist{})
+
+		var shared = &Artist{Name: "Shared"}
+		var value = &telepath.ValidationError{
+			Params: map[string]interface{}{"a": shared, "b": shared, "c": []string{"x"}, "d": []string{"y"}},
+			Fields: map[string]error{
+				"one":   &telepath.ValidationError{Message: "One", Params: map[string]interface{}{"artist": shared}},
+				"two":   &telepath.ValidationError{Message: "Two", Params: map[string]interface{}{"artist": shared}},
+				"three": &telepath.ValidationError{Message: "Three", Params: map[string]interface{}{"artist": shared}},
+			},
+		}
+
+		var first, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for i := 0; i < 20; i++ {
+			var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+			if err != nil || result != first {
+				t.Fatalf("Expected %v, got %v (%v)", first, result, err)
+			}
+		}
+	})
+}
+
+func TestWriteTypeScript(t *testing.T) {
+	telepath.Register(AlbumAdapter, &Album{})
+	telepath.Register(ArtistAdapter, &Artist{})
+
+	var b strings.Builder
+	if err := telepath.GlobalRegistry.WriteTypeScript(&b); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected = []string{
+		`    "js.funcs.Album": [name: string, artists: (TelepathObject<"js.funcs.Artist"> | null)[]];`,
+		`    "js.funcs.Artist": any[];`,
+		`export type TelepathConstructors = {`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("Expected %v in %v", line, b.String())
+		}
+	}
+
+	t.Run("TestConflict", func(t *testing.T) {
+		type Conflicting struct{}
+
+		var registry = telepath.GlobalRegistry.Clone()
+		registry.Register(&telepath.ObjectAdapter[Conflicting]{
+			JSConstructor: "js.funcs.Album",
+			Args:          []telepath.JSArg{telepath.Arg[int]("id")},
+		}, Conflicting{})
+
+		var err = registry.WriteTypeScript(&strings.Builder{})
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("TestEnumsAndPointers", func(t *testing.T) {
+		type Palette struct{}
+
+		var tests = []struct {
+			mode     telepath.EnumMode
+			expected string
+		}{
+			{telepath.ENUM_MODE_NAME, `"Blue" | "Green" | "Red"`},
+			{telepath.ENUM_MODE_CONSTANT, `TelepathObject<"Enum.Color">`},
+			{telepath.ENUM_MODE_VALUE, `number`},
+		}
+
+		for _, test := range tests {
+			var registry = telepath.GlobalRegistry.Clone()
+			var enum = telepath.StringerEnumAdapter("Enum.Color", Red, Green, Blue)
+			enum.Mode = test.mode
+			registry.Register(enum, Red)
+			registry.Register(&telepath.ObjectAdapter[Palette]{
+				JSConstructor: "js.funcs.Palette",
+				Args: []telepath.JSArg{
+					telepath.Arg[Color]("primary"),
+					telepath.Arg[*Color]("secondary"),
+					telepath.OptionalArg[*Color]("accent"),
+					telepath.Arg[*string]("name"),
+				},
+			}, Palette{})
+
+			var b strings.Builder
+			if err := registry.WriteTypeScript(&b); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+
+			var expected = fmt.Sprintf(
+				`    "js.funcs.Palette": [primary: %[1]s, secondary: %[1]s | null, accent?: %[1]s | null, name: string | null];`,
+				test.expected,
+			)
+			if !strings.Contains(b.String(), expected+"\n") {
+				t.Errorf("Expected %v in %v", expected, b.String())
+			}
+
+			var constructor = `    "Enum.Color": [name: string];`
+			if hasConstructor := strings.Contains(b.String(), constructor+"\n"); hasConstructor != (test.mode == telepath.ENUM_MODE_CONSTANT) {
+				t.Errorf("Expected %v in %v: %v", constructor, b.String(), test.mode == telepath.ENUM_MODE_CONSTANT)
+			}
+		}
+	})
+}
+
+type Review struct {
+	Title  string
+	Rating interface{}
+	Author *Artist
+}
+
+func TestValidateArgs(t *testing.T) {
+	var ReviewAdapter = &telepath.ObjectAdapter[*Review]{
+		JSConstructor: "js.funcs.Review",
+		GetJSArgs: func(obj *Review) []interface{} {
+			var args = []interface{}{obj.Title, obj.Rating}
+			if obj.Author != nil {
+				args = append(args, obj.Author)
+			}
+			return args
+		},
+		Args: []telepath.JSArg{
+			telepath.Arg[string]("title"),
+			telepath.Arg[int]("rating"),
+			telepath.OptionalArg[*Artist]("author"),
+		},
+	}
+
+	telepath.Register(ReviewAdapter, &Review{})
+	telepath.Register(ArtistAdapter, &Artist{})
+
+	var tests = []struct {
+		name   string
+		review *Review
+		path   string
+	}{
+		{"TestValid", &Review{Title: "Review", Rating: 5, Author: &Artist{Name: "Artist"}}, ""},
+		{"TestOptional", &Review{Title: "Review", Rating: 5}, ""},
+		{"TestWrongType", &Review{Title: "Review", Rating: "5"}, "$[0]._args[1]"},
+		{"TestRequiredNil", &Review{Title: "Review"}, "$[0]._args[1]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var jsContext = telepath.NewContext()
+			jsContext.DevMode = true
+
+			var _, err = telepath.PackJSON(context.Background(), jsContext, []interface{}{test.review})
+			if test.path == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var pathErr *telepath.PathError
+			if !errors.As(err, &pathErr) || !errors.Is(err, telepath.ErrInvalidArgs) {
+				t.Errorf("Expected invalid arguments error, got %v", err)
+				return
+			}
+
+			if pathErr.PathString() != test.path {
+				t.Errorf("Expected %v, got %v", test.path, pathErr.PathString())
+			}
+		})
+	}
+
+	t.Run("TestDisabled", func(t *testing.T) {
+		var _, err = telepath.PackJSON(context.Background(), telepath.NewContext(), &Review{Title: "Review", Rating: "5"})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+
+	t.Run("TestArgCount", func(t *testing.T) {
+		var err = telepath.ValidateArgs("js.funcs.Review", ReviewAdapter.Args, []interface{}{"Review"})
+		if !errors.Is(err, telepath.ErrInvalidArgs) {
+			t.Errorf("Expected %v, got %v", telepath.ErrInvalidArgs, err)
+		}
+
+		err = telepath.ValidateArgs("js.funcs.Review", ReviewAdapter.Args, []interface{}{"Review", 5, nil, nil})
+		if !errors.Is(err, telepath.ErrInvalidArgs) {
+			t.Errorf("Expected %v, got %v", telepath.ErrInvalidArgs, err)
+		}
+	})
+
+	t.Run("TestTypeScript", func(t *testing.T) {
+		var b strings.Builder
+		if err := telepath.GlobalRegistry.WriteTypeScript(&b); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		var expected = `"js.funcs.Review": [title: string, rating: number, author?: TelepathObject<"js.funcs.Artist"> | null];`
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("Expected %v in %v", expected, b.String())
+		}
+	})
+}
+
+type Playlist struct {
+	Name    string
+	Artists []*Artist
+	Public  bool
+}
+
+func TestPackKwargs(t *testing.T) {
+	var PlaylistAdapter = &telepath.ObjectAdapter[*Playlist]{
+		JSConstructor: "js.funcs.Playlist",
+		GetJSKwargs: func(obj *Playlist) map[string]interface{} {
+			return map[string]interface{}{
+				"name":    obj.Name,
+				"artists": obj.Artists,
+				"public":  obj.Public,
+			}
+		},
+		Args: []telepath.JSArg{
+			telepath.Arg[string]("name"),
+			telepath.Arg[[]*Artist]("artists"),
+			telepath.OptionalArg[bool]("public"),
+		},
+	}
+
+	telepath.Register(PlaylistAdapter, &Playlist{})
+	telepath.Register(ArtistAdapter, &Artist{})
+
+	var artist = &Artist{Name: "Artist"}
+	var value = &Playlist{Name: "Playlist", Artists: []*Artist{artist, artist}}
+
+	var jsContext = telepath.NewContext()
+	jsContext.DevMode = true
+
+	var result, err = telepath.PackJSON(context.Background(), jsContext, value)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected = `{"_type":"js.funcs.Playlist","_args":[{"artists":{"_list":[` +
+		`{"_type":"js.funcs.Artist","_args":["Artist"],"_id":1},{"_ref":1}]},"name":"Playlist","public":false}]}`
+
+	if result != expected {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	t.Run("TestUnpack", func(t *testing.T) {
+		var vm = goja.New()
+		if _, err = vm.RunString(telepath_js); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		vm.Set("testData", result)
+		var chk, _ = vm.RunString(`TELEPATH.register("js.funcs.Artist", function (name) { this.name = name; });
+			TELEPATH.register("js.funcs.Playlist", function (kwargs) { this.name = kwargs.name; this.artists = kwargs.artists; });
+			var playlist = TELEPATH.unpack(JSON.parse(testData));
+			playlist.artists[0] === playlist.artists[1] && playlist.name + "|" + playlist.artists[0].name`)
+
+		if chk.String() != "Playlist|Artist" {
+			t.Errorf("Expected Playlist|Artist, got %v (%v)", chk, result)
+		}
+	})
+
+	t.Run("TestValidation", func(t *testing.T) {
+		var err = telepath.ValidateArgs("js.funcs.Playlist", []telepath.JSArg{{Name: "kwargs", Fields: PlaylistAdapter.Args}}, []interface{}{
+			map[string]interface{}{"name": "Playlist", "artists": nil, "owner": "Someone"},
+		})
+		if !errors.Is(err, telepath.ErrInvalidArgs) {
+			t.Errorf("Expected %v, got %v", telepath.ErrInvalidArgs, err)
+		}
+
+		err = telepath.ValidateArgs("js.funcs.Playlist", []telepath.JSArg{{Name: "kwargs", Fields: PlaylistAdapter.Args}}, []interface{}{
+			map[string]interface{}{"name": 42, "artists": nil},
+		})
+		if !errors.Is(err, telepath.ErrInvalidArgs) {
+			t.Errorf("Expected %v, got %v", telepath.ErrInvalidArgs, err)
+		}
+	})
+
+	t.Run("TestTypeScript", func(t *testing.T) {
+		var b strings.Builder
+		if err := telepath.GlobalRegistry.WriteTypeScript(&b); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		var expected = `"js.funcs.Playlist": [kwargs: {name: string; artists: (TelepathObject<"js.funcs.Artist"> | null)[]; public?: boolean | null}];`
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("Expected %v in %v", expected, b.String())
+		}
+	})
+}
+
+type Widget struct {
+	Label string
+}
+
+type WidgetMedia struct{}
+
+func (m *WidgetMedia) Merge(other telepath.Media) telepath.Media { return m }
+func (m *WidgetMedia) CSS() []template.HTML                      { return []template.HTML{"widget.css"} }
+func (m *WidgetMedia) JS() []template.HTML                       { return []template.HTML{"widget.js"} }
+
+func TestManifest(t *testing.T) {
+	var WidgetAdapter = &telepath.ObjectAdapter[*Widget]{
+		JSConstructor: "js.funcs.Widget",
+		GetJSArgs: func(obj *Widget) []interface{} {
+			return []interface{}{obj.Label}
+		},
+		Media: &WidgetMedia{},
+		Args: []telepath.JSArg{
+			telepath.OptionalArg[string]("label"),
+		},
+	}
+
+	telepath.Register(WidgetAdapter, &Widget{})
+
+	var manifest, err = telepath.GlobalRegistry.Manifest()
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var widget *telepath.ManifestConstructor
+	for i, constructor := range manifest.Constructors {
+		if i > 0 && manifest.Constructors[i-1].Name >= constructor.Name {
+			t.Errorf("Expected constructors sorted by name, got %v before %v", manifest.Constructors[i-1].Name, constructor.Name)
+		}
+		if constructor.Name == "js.funcs.Widget" {
+			widget = &manifest.Constructors[i]
+		}
+	}
+
+	if widget == nil {
+		t.Errorf("Expected js.funcs.Widget in manifest, got %v", manifest.Constructors)
+		return
+	}
+
+	b, err := json.Marshal(widget)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected = `{"name":"js.funcs.Widget",` +
+		`"types":["*github.com/Nigel2392/go-telepath/telepath_test.Widget","github.com/Nigel2392/go-telepath/telepath_test.Widget"],` +
+		`"media":{"css":["widget.css"],"js":["widget.js"]},` +
+		`"args":[{"name":"label","type":"string","tsType":"string","optional":true}]}`
+
+	if string(b) != expected {
+		t.Errorf("Expected %v, got %v", expected, string(b))
+	}
+
+	t.Run("TestEnum", func(t *testing.T) {
+		for _, mode := range []telepath.EnumMode{telepath.ENUM_MODE_NAME, telepath.ENUM_MODE_CONSTANT, telepath.ENUM_MODE_VALUE} {
+			var registry = telepath.GlobalRegistry.Clone()
+			var enum = telepath.StringerEnumAdapter("Enum.Color", Red, Green, Blue)
+			enum.Mode = mode
+			registry.Register(enum, Red)
+
+			var manifest, err = registry.Manifest()
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+
+			var found bool
+			for _, constructor := range manifest.Constructors {
+				if constructor.Name != "Enum.Color" {
+					continue
+				}
+				found = true
+
+				b, err := json.Marshal(constructor)
+				var expected = `{"name":"Enum.Color",` +
+					`"types":["github.com/Nigel2392/go-telepath/telepath_test.Color"],` +
+					`"media":{"css":[],"js":[]},` +
+					`"args":[{"name":"name","type":"string","tsType":"string"}]}`
+				if err != nil || string(b) != expected {
+					t.Errorf("Expected %v, got %v (%v)", expected, string(b), err)
+				}
+			}
+
+			if found != (mode == telepath.ENUM_MODE_CONSTANT) {
+				t.Errorf("Expected Enum.Color in manifest: %v, got %v", mode == telepath.ENUM_MODE_CONSTANT, manifest.Constructors)
+			}
+		}
+	})
+
+	t.Run("TestMedia", func(t *testing.T) {
+		var jsContext = telepath.NewContext()
+		if _, err := jsContext.Pack(context.Background(), &Widget{Label: "Widget"}); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if js := jsContext.Media.JS(); len(js) != 1 || js[0] != "widget.js" {
+			t.Errorf("Expected [widget.js], got %v", js)
+		}
+	})
+}
+
+type FuzzNode struct {
+	Children []interface{}
+}
+
+var FuzzNodeAdapter = &telepath.ObjectAdapter[*FuzzNode]{
+	JSConstructor: "fuzz.Node",
+	GetJSArgs: func(obj *FuzzNode) []interface{} {
+		return obj.Children
+	},
+}
+
+var fuzzKeys = []string{"a", "b", "c", "_args", "_dict", "_format", "_id", "_list", "_ref", "_type", "_val"}
+
+// fuzzGenerator builds a value graph from fuzz input,
+// containers are only shared after they have been built so the graph has no cycles.
+type fuzzGenerator struct {
+	data   []byte
+	pos    int
+	shared []interface{}
+}
+
+func (g *fuzzGenerator) next() int {
+	if g.pos >= len(g.data) {
+		return 0
+	}
+	g.pos++
+	return int(g.data[g.pos-1])
+}
+
+func (g *fuzzGenerator) children(depth int) []interface{} {
+	var children = make([]interface{}, g.next()%4)
+	for i := range children {
+		children[i] = g.value(depth + 1)
+	}
+	return children
+}
+
+func (g *fuzzGenerator) value(depth int) interface{} {
+	var kind = g.next() % 10
+	if depth > 4 && kind >= 5 {
+		kind %= 5
+	}
+
+	var value interface{}
+	switch kind {
+	case 0:
+		return nil
+	case 1:
+		return g.next()%2 == 0
+	case 2:
+		return int(int8(g.next()))
+	case 3:
+		return fmt.Sprintf("s%d", g.next())
+	case 4:
+		var s = fmt.Sprintf("a long string which is referenced %d", g.next())
+		value = &s
+	case 5:
+		value = g.children(depth)
+	case 6:
+		var dict = make(map[string]interface{})
+		for n := g.next() % 4; n > 0; n-- {
+			dict[fuzzKeys[g.next()%len(fuzzKeys)]] = g.value(depth + 1)
+		}
+		value = dict
+	case 7:
+		value = &FuzzNode{Children: g.children(depth)}
+	case 8:
+		var list = make([]string, g.next()%3)
+		for i := range list {
+			list[i] = fmt.Sprintf("item %d", g.next())
+		}
+		value = list
+	case 9:
+		if len(g.shared) == 0 {
+			return nil
+		}
+		return g.shared[g.next()%len(g.shared)]
+	}
+
+	g.shared = append(g.shared, value)
+	return value
+}
+
+// fuzzDescriber describes a value graph in a canonical form, containers are numbered
+// in the order they are first visited and described as a reference when visited again.
+type fuzzDescriber struct {
+	ids map[fuzzIdentity]int
+}
+
+type fuzzIdentity struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+func (d *fuzzDescriber) visit(rVal reflect.Value, length int) (string, bool) {
+	if d.ids == nil {
+		d.ids = make(map[fuzzIdentity]int)
+	}
+	var key = fuzzIdentity{rVal.Type(), rVal.Pointer(), length}
+	if id, ok := d.ids[key]; ok {
+		return fmt.Sprintf("@%d", id), false
+	}
+	d.ids[key] = len(d.ids) + 1
+	return fmt.Sprintf("#%d", len(d.ids)), true
+}
+
+func (d *fuzzDescriber) list(rVal reflect.Value, items []interface{}) string {
+	if len(items) == 0 {
+		return "[]"
+	}
+
+	var tag, first = d.visit(rVal, len(items))
+	if !first {
+		return tag
+	}
+
+	var parts = make([]string, len(items))
+	for i, item := range items {
+		parts[i] = d.describe(item)
+	}
+	return tag + "[" + strings.Join(parts, ",") + "]"
+}
+
+func (d *fuzzDescriber) describe(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprint(v)
+	case string:
+		var b, _ = json.Marshal(v)
+		return string(b)
+	case *string:
+		return d.describe(*v)
+	case []string:
+		var items = make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return d.list(reflect.ValueOf(v), items)
+	case []interface{}:
+		return d.list(reflect.ValueOf(v), v)
+	case map[string]interface{}:
+		var tag, first = d.visit(reflect.ValueOf(v), 0)
+		if !first {
+			return tag
+		}
+		var keys = make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var parts = make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = d.describe(key) + ":" + d.describe(v[key])
+		}
+		return tag + "{" + strings.Join(parts, ",") + "}"
+	case *FuzzNode:
+		var tag, first = d.visit(reflect.ValueOf(v), 0)
+		if !first {
+			return tag
+		}
+		var parts = make([]string, len(v.Children))
+		for i, child := range v.Children {
+			parts[i] = d.describe(child)
+		}
+		return tag + "fuzz.Node(" + strings.Join(parts, ",") + ")"
+	}
+
+	// Decoders return different number types.
+	var rVal = reflect.ValueOf(value)
+	switch rVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rVal.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rVal.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rVal.Float(), 'g', -1, 64)
+	}
+	return fmt.Sprintf("<unexpected %T>", value)
+}
+
+const fuzzDescribeJS = `(function (value) {
+	var ids = new Map();
+	function visit(v) {
+		if (ids.has(v)) {
+			return ["@" + ids.get(v), false];
+		}
+		ids.set(v, ids.size + 1);
+		return ["#" + ids.size, true];
+	}
+	function describe(v) {
+		if (v === null) {
+			return "null";
+		}
+		if (typeof v !== "object") {
+			return typeof v === "string" ? JSON.stringify(v) : String(v);
+		}
+		if (Array.isArray(v) && v.length === 0) {
+			return "[]";
+		}
+		var tag = visit(v);
+		if (!tag[1]) {
+			return tag[0];
+		}
+		if (Array.isArray(v)) {
+			return tag[0] + "[" + v.map(describe).join(",") + "]";
+		}
+		if (v instanceof FuzzNode) {
+			return tag[0] + "fuzz.Node(" + v.children.map(describe).join(",") + ")";
+		}
+		return tag[0] + "{" + Object.keys(v).sort().map(function (key) {
+			return JSON.stringify(key) + ":" + describe(v[key]);
+		}).join(",") + "}";
+	}
+	return describe(value);
+})`
+
+func FuzzPackUnpack(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{5, 3, 7, 2, 4, 1, 9, 0})
+	f.Add([]byte{6, 3, 5, 1, 6, 0, 7, 1, 9, 0, 9, 1})
+	f.Add([]byte{5, 3, 6, 2, 3, 5, 6, 0, 9, 1, 9, 0, 8, 2, 1, 2})
+	f.Add([]byte{7, 3, 4, 1, 9, 0, 5, 2, 9, 0, 9, 1, 6, 1, 9, 1})
+
+	telepath.Register(FuzzNodeAdapter, &FuzzNode{})
+
+	var unpacker = telepath.NewUnpacker()
+	unpacker.Register("fuzz.Node", func(args []interface{}) (interface{}, error) {
+		return &FuzzNode{Children: args}, nil
+	})
+
+	var rt, err = telepathtest.NewRuntime()
+	if err != nil {
+		f.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err = rt.Register("fuzz.Node", `function FuzzNode() { this.children = Array.prototype.slice.call(arguments); }`); err != nil {
+		f.Fatalf("Expected no error, got %v", err)
+	}
+
+	describeJS, err := rt.VM.RunString(fuzzDescribeJS)
+	if err != nil {
+		f.Fatalf("Expected no error, got %v", err)
+	}
+	rt.VM.Set("FuzzNode", rt.VM.Get("TELEPATH").ToObject(rt.VM).Get("constructors").ToObject(rt.VM).Get("fuzz.Node"))
+	var describeFn, _ = goja.AssertFunction(describeJS)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var value = (&fuzzGenerator{data: data}).value(0)
+		var expected = (&fuzzDescriber{}).describe(value)
+
+		var packed, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		var parallelContext = telepath.NewContext()
+		parallelContext.Parallelism = 4
+		parallelContext.ParallelMinItems = 1
+		parallelPacked, err := telepath.PackJSON(context.Background(), parallelContext, value)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if parallelPacked != packed {
+			t.Errorf("Expected parallel output %v, got %v", packed, parallelPacked)
+		}
+
+		unpacked, err := unpacker.UnpackJSON([]byte(packed))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v (%v)", err, packed)
+		}
+
+		if got := (&fuzzDescriber{}).describe(unpacked); got != expected {
+			t.Errorf("Go unpacker: expected %v, got %v (%v)", expected, got, packed)
+		}
+
+		for _, encoding := range []interface {
+			telepath.Encoder
+			telepath.Decoder
+		}{telepath.CBOREncoding, telepath.MsgpackEncoding} {
+			var encoded, err = telepath.PackEncoded(context.Background(), telepath.NewContext(), value, encoding)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			unpacked, err := unpacker.UnpackEncoded(encoded, encoding)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v (%v)", err, packed)
+			}
+
+			if got := (&fuzzDescriber{}).describe(unpacked); got != expected {
+				t.Errorf("%s: expected %v, got %v (%v)", encoding.ContentType(), expected, got, packed)
+			}
+		}
+
+		var compactContext = telepath.NewContext()
+		compactContext.Format = telepath.FORMAT_VERSION_COMPACT
+		compactPacked, err := telepath.PackJSON(context.Background(), compactContext, value)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		unpacked, err = unpacker.UnpackJSON([]byte(compactPacked))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v (%v)", err, compactPacked)
+		}
+
+		if got := (&fuzzDescriber{}).describe(unpacked); got != expected {
+			t.Errorf("Go unpacker (compact): expected %v, got %v (%v)", expected, got, compactPacked)
+		}
+
+		for _, p := range []string{packed, compactPacked} {
+			result, err := rt.Unpack(p)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v (%v)", err, p)
+			}
+
+			described, err := describeFn(goja.Undefined(), result.Value)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if got := described.String(); got != expected {
+				t.Errorf("JS unpacker: expected %v, got %v (%v)", expected, got, p)
+			}
+		}
+	})
+}
+
+func TestUnpacker(t *testing.T) {
+	var shared = map[string]interface{}{}
+	var value = []interface{}{shared, shared, []interface{}{}, &FuzzNode{}}
+
+	telepath.Register(FuzzNodeAdapter, &FuzzNode{})
+
+	var packed, err = telepath.PackJSON(context.Background(), telepath.NewContext(), value)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var expected = `{"_list":[{"_dict":{},"_id":1},{"_ref":1},{"_list":[]},{"_type":"fuzz.Node","_args":[]}]}`
+	if packed != expected {
+		t.Errorf("Expected %v, got %v", expected, packed)
+	}
+
+	unpacked, err := telepath.UnpackJSON([]byte(packed))
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	var list = unpacked.([]interface{})
+	if reflect.ValueOf(list[0]).Pointer() != reflect.ValueOf(list[1]).Pointer() {
+		t.Errorf("Expected shared dict, got %v and %v", list[0], list[1])
+	}
+
+	if obj, ok := list[3].(*telepath.UnpackedObject); !ok || obj.Type != "fuzz.Node" {
+		t.Errorf("Expected unpacked fuzz.Node, got %v", list[3])
+	}
+
+	t.Run("TestUnknownRef", func(t *testing.T) {
+		var _, err = telepath.UnpackJSON([]byte(`{"_list":[{"_ref":2}]}`))
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("TestSameAddress", func(t *testing.T) {
+		telepath.Register(AlbumAdapter, &Album{})
+
+		var album = &Album{Name: "This album name is long enough to be referenced"}
+		var result, err = telepath.PackJSON(context.Background(), telepath.NewContext(), []interface{}{album, &album.Name})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		var expected = `{"_list":[{"_type":"js.funcs.Album","_args":["This album name is long enough to be referenced",{"_list":[]}]},` +
+			`"This album name is long enough to be referenced"]}`
+		if result != expected {
+			t.Errorf("Expected %v, got %v", expected, result)
+		}
+	})
+}
+
+// Tags are packed as a typed slice of raw JSON, like nodes of custom adapters might emit.
+type Tags []string
+
+type TagsAdapter struct{}
+
+func (a *TagsAdapter) BuildNode(ctx context.Context, value interface{}, c telepath.Context) (telepath.Node, error) {
+	var tags = value.(Tags)
+	var raw = make([]json.RawMessage, len(tags))
+	for i, tag := range tags {
+		raw[i] = json.RawMessage(strconv.Quote(tag))
+	}
+	return telepath.NewPrimitiveNode(raw), nil
+}
+
+func TestPackEncoded(t *testing.T) {
+	telepath.Register(AlbumAdapter, &Album{})
+	telepath.Register(ArtistAdapter, &Artist{})
+
+	var registry = telepath.GlobalRegistry.Clone()
+	registry.Register(&TagsAdapter{}, Tags{})
+
+	var newContext = func() *telepath.JSContext {
+		var c = registry.Context()
+		c.FloatPolicy = telepath.FLOAT_POLICY_MARKER
+		c.BigIntPolicy = telepath.BIGINT_POLICY_BIGINT
+		return c
+	}
+
+	var artist = &Artist{Name: "Artist"}
+	var value = []interface{}{
+		&Album{Name: "Album", Artists: []*Artist{artist, artist}},
+		json.RawMessage(`{"_type":"raw"}`),
+		[]int{1, 2, 3},
+		[]float64{1.5, math.Inf(1)},
+		[]int64{1, 1 << 60},
+		Tags{"a", "b"},
+		uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+	}
+
+	var encodings = []interface {
+		telepath.Encoder
+		telepath.Decoder
+	}{telepath.JSONEncoding, telepath.CBOREncoding, telepath.MsgpackEncoding}
+
+	var packedJSON, err = telepath.PackEncoded(context.Background(), newContext(), value, telepath.JSONEncoding)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, encoding := range encodings {
+		t.Run(encoding.ContentType(), func(t *testing.T) {
+			var encoded, err = telepath.PackEncoded(context.Background(), newContext(), value, encoding)
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+
+			if encoding != telepath.JSONEncoding && len(encoded) >= len(packedJSON) {
+				t.Errorf("Expected less than %d bytes, got %d", len(packedJSON), len(encoded))
+			}
+
+			var unpacker = telepath.NewUnpacker()
+			for _, name := range []string{telepath.FLOAT_JS_CONSTRUCTOR, telepath.BIGINT_JS_CONSTRUCTOR} {
+				var name = name
+				unpacker.Register(name, func(args []interface{}) (interface{}, error) {
+					return fmt.Sprintf("%s(%v)", name, args[0]), nil
+				})
+			}
+
+			unpacked, err := unpacker.UnpackEncoded(encoded, encoding)
+			if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+				return
+			}
+
+			var list = unpacked.([]interface{})
+			var album = list[0].(*telepath.UnpackedObject)
+			var artists = album.Args[1].([]interface{})
+			if album.Type != "js.funcs.Album" || artists[0] != artists[1] {
+				t.Errorf("Expected album with shared artists, got %v", album)
+			}
+
+			if raw := list[1].(map[string]interface{}); raw["_type"] != "raw" {
+				t.Errorf("Expected raw JSON value, got %v", raw)
+			}
+
+			var expected = []string{
+				"[1 2 3]",
+				"[1.5 telepath.Float(Infinity)]",
+				"[1 telepath.BigInt(1152921504606846976)]",
+				"[a b]",
+				"6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+			}
+			for i, want := range expected {
+				if got := fmt.Sprint(list[i+2]); got != want {
+					t.Errorf("Expected %v, got %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPackCompact(t *testing.T) {
+	telepath.Register(AlbumAdapter, &Album{})
+	telepath.Register(ArtistAdapter, &Artist{})
+
+	var artist = &Artist{Name: "Artist"}
+	var value = []interface{}{
+		&Album{Name: "First", Artists: []*Artist{artist, {Name: "Other"}}},
+		&Album{Name: "Second", Artists: []*Artist{artist}},
+		map[string]interface{}{"_t": 1, "_d": "dict"},
+		map[string]interface{}{"_format": 1},
+		json.RawMessage(`{"_t":0}`),
+	}
+
+	var verboseContext = telepath.NewContext()
+	verbose, err := telepath.PackJSON(context.Background(), verboseContext, value)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var compactContext = telepath.NewContext()
+	compactContext.Format = telepath.FORMAT_VERSION_COMPACT
+	compact, err := telepath.PackJSON(context.Background(), compactContext, value)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	t.Run("Envelope", func(t *testing.T) {
+		var envelope struct {
+			Format int      `json:"_format"`
+			Types  []string `json:"_types"`
+		}
+		if err := json.Unmarshal([]byte(compact), &envelope); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if envelope.Format != telepath.FORMAT_VERSION_COMPACT {
+			t.Errorf("Expected format %d, got %d", telepath.FORMAT_VERSION_COMPACT, envelope.Format)
+		}
+
+		if got := strings.Join(envelope.Types, ","); got != "js.funcs.Album,js.funcs.Artist" {
+			t.Errorf("Expected js.funcs.Album,js.funcs.Artist, got %v", got)
+		}
+
+		if n := strings.Count(compact, "js.funcs.Album"); n != 1 {
+			t.Errorf("Expected constructor name once, got %d times in %v", n, compact)
+		}
+
+		if strings.Contains(compact, `"_type"`) || strings.Contains(compact, `"_args"`) {
+			t.Errorf("Expected only short keys, got %v", compact)
+		}
+
+		if len(compact) >= len(verbose) {
+			t.Errorf("Expected less than %d bytes, got %d", len(verbose), len(compact))
+		}
+	})
+
+	t.Run("Go", func(t *testing.T) {
+		var verboseUnpacked, err = telepath.UnpackJSON([]byte(verbose))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		compactUnpacked, err := telepath.UnpackJSON([]byte(compact))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if !reflect.DeepEqual(verboseUnpacked, compactUnpacked) {
+			t.Errorf("Expected %v, got %v", verboseUnpacked, compactUnpacked)
+		}
+
+		var list = compactUnpacked.([]interface{})
+		var first = list[0].(*telepath.UnpackedObject).Args[1].([]interface{})
+		var second = list[1].(*telepath.UnpackedObject).Args[1].([]interface{})
+		if first[0] != second[0] {
+			t.Errorf("Expected shared artist, got %v and %v", first[0], second[0])
+		}
+	})
+
+	t.Run("JS", func(t *testing.T) {
+		var rt, err = telepathtest.NewRuntime()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		err = rt.RegisterAll(map[string]string{
+			"js.funcs.Album":  `function Album(name, artists) { this.name = name; this.artists = artists; }`,
+			"js.funcs.Artist": `function Artist(name) { this.name = name; }`,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		var results = make([]string, 2)
+		for i, packed := range []string{verbose, compact} {
+			var result, err = rt.Unpack(packed)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if results[i], err = result.Stringify(); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			shared, err := result.Eval("value[0].artists[0] === value[1].artists[0]")
+			if err != nil || !shared.ToBoolean() {
+				t.Errorf("Expected shared artist, got %v (%v)", shared, err)
+			}
+		}
+
+		if results[0] != results[1] {
+			t.Errorf("Expected %v, got %v", results[0], results[1])
+		}
+	})
+
+	t.Run("ReservedKeys", func(t *testing.T) {
+		var dict = map[string]interface{}{"_a": 1}
+
+		var verbose, err = telepath.PackJSON(context.Background(), telepath.NewContext(), dict)
+		if err != nil || verbose != `{"_a":1}` {
+			t.Errorf("Expected {\"_a\":1}, got %v (%v)", verbose, err)
+		}
+
+		var compactContext = telepath.NewContext()
+		compactContext.Format = telepath.FORMAT_VERSION_COMPACT
+		compact, err := telepath.PackJSON(context.Background(), compactContext, dict)
+		var expected = `{"_format":2,"_types":[],"_value":{"_d":{"_a":1}}}`
+		if err != nil || compact != expected {
+			t.Errorf("Expected %v, got %v (%v)", expected, compact, err)
+		}
+	})
+
+	t.Run("FormatKey", func(t *testing.T) {
+		var dict = map[string]interface{}{"_format": 1, "_value": "x", "other": 3}
+
+		var packed, err = telepath.PackJSON(context.Background(), telepath.NewContext(), dict)
+		var expected = `{"_dict":{"_format":1,"_value":"x","other":3}}`
+		if err != nil || packed != expected {
+			t.Errorf("Expected %v, got %v (%v)", expected, packed, err)
+		}
+
+		unpacked, err := telepath.UnpackJSON([]byte(packed))
+		if err != nil || fmt.Sprint(unpacked) != "map[_format:1 _value:x other:3]" {
+			t.Errorf("Expected map[_format:1 _value:x other:3], got %v (%v)", unpacked, err)
+		}
+
+		rt, err := telepathtest.NewRuntime()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		result, err := rt.Unpack(packed)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got, err := result.Stringify(); err != nil || got != `{"_format":1,"_value":"x","other":3}` {
+			t.Errorf("Expected {\"_format\":1,\"_value\":\"x\",\"other\":3}, got %v (%v)", got, err)
+		}
+	})
+
+	t.Run("InvalidConstructorIndex", func(t *testing.T) {
+		var rt, err = telepathtest.NewRuntime()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err = rt.Register("js.funcs.Album", `function Album() {}`); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		for _, index := range []string{`"0"`, `-1`, `1`, `0.5`} {
+			var packed = `{"_format":2,"_types":["js.funcs.Album"],"_value":{"_t":` + index + `,"_a":[]}}`
+			if _, err := rt.Unpack(packed); err == nil {
+				t.Errorf("Expected error for index %s, got nil", index)
+			}
+			if _, err := telepath.UnpackJSON([]byte(packed)); err == nil {
+				t.Errorf("Expected error for index %s, got nil", index)
+			}
+		}
+	})
+
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		var _, err = telepath.UnpackJSON([]byte(`{"_format":3,"_value":null}`))
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+
+		var ctx = telepath.NewContext()
+		ctx.Format = 3
+		if _, err = ctx.Pack(context.Background(), value); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package telepathtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Nigel2392/go-telepath/telepath"
+)
+
+const GOLDEN_DIR = "testdata"
+
+// The flag is prefixed so it does not clash with an -update flag of the tested package.
+var update = flag.Bool("telepathtest.update", false, "update the golden files of telepathtest.Golden")
+
+var refPattern = regexp.MustCompile(`"(_id|_ref)": \d+`)
+
+// Golden packs the value with the global registry and compares the pretty-printed
+// JSON to testdata/<name>.golden.json, run the tests with -telepathtest.update to write the file.
+func Golden(t testing.TB, name string, value interface{}) {
+	t.Helper()
+	GoldenContext(t, telepath.NewContext(), name, value)
+}
+
+// GoldenContext is like Golden but packs the value with the given context.
+func GoldenContext(t testing.TB, jsContext *telepath.JSContext, name string, value interface{}) {
+	t.Helper()
+
+	var packed, err = telepath.PackJSON(context.Background(), jsContext, value)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var pretty bytes.Buffer
+	if err = json.Indent(&pretty, []byte(packed), "", "  "); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	pretty.WriteString("\n")
+
+	var path = filepath.Join(GOLDEN_DIR, name+".golden.json")
+	if *update {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err = os.WriteFile(path, pretty.Bytes(), 0644); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v (run with -telepathtest.update to create it)", err)
+	}
+
+	var got = pretty.String()
+	var want = strings.ReplaceAll(string(expected), "\r\n", "\n")
+	if got == want {
+		return
+	}
+
+	var hint string
+	if refPattern.ReplaceAllString(got, `"$1": N`) == refPattern.ReplaceAllString(want, `"$1": N`) {
+		hint = "only the _id/_ref numbering changed, "
+	}
+
+	t.Errorf("Packed value does not match %s, %srun with -telepathtest.update if this is expected:\n%s", path, hint, diffLines(want, got))
+}
+
+// diffLines returns a line diff of a and b, removed lines are prefixed with -
+// and added lines with +, unchanged lines close to changes are kept as context.
+func diffLines(a, b string) string {
+	var (
+		x = strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+		y = strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+	)
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	var lcs = make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+
+	var lines = make([]line, 0, len(x)+len(y))
+	var i, j = 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, line{' ', x[i]})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', x[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', y[j]})
+			j++
+		}
+	}
+
+	const contextLines = 3
+	var out strings.Builder
+	var lastWritten = -1
+	for k, l := range lines {
+		var near = false
+		for d := max(0, k-contextLines); d <= min(len(lines)-1, k+contextLines); d++ {
+			if lines[d].op != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if lastWritten >= 0 && k > lastWritten+1 {
+			out.WriteString("  ...\n")
+		}
+		fmt.Fprintf(&out, "%c %s\n", l.op, l.text)
+		lastWritten = k
+	}
+	return out.String()
+}
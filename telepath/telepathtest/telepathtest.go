@@ -0,0 +1,195 @@
+// Package telepathtest provides utilities for testing how packed values are unpacked
+// by the bundled JS unpacker, using the goja JS engine.
+package telepathtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Nigel2392/go-telepath/telepath"
+	"github.com/dop251/goja"
+)
+
+const TELEPATH_JS_PATH = "static/telepath/telepath.js"
+
+// Runtime is a JS runtime with the bundled unpacker loaded,
+// the Telepath instance is available as the global TELEPATH.
+type Runtime struct {
+	VM *goja.Runtime
+}
+
+// NewRuntime returns a fresh runtime, constructors registered on
+// a runtime are not shared with other runtimes.
+func NewRuntime() (*Runtime, error) {
+	var source, err = telepath.TelepathJS.ReadFile(TELEPATH_JS_PATH)
+	if err != nil {
+		return nil, err
+	}
+
+	var vm = goja.New()
+	if _, err = vm.RunScript(TELEPATH_JS_PATH, string(source)); err != nil {
+		return nil, fmt.Errorf("loading unpacker: %w", err)
+	}
+
+	if _, err = vm.RunString(`var TELEPATH = new Telepath();`); err != nil {
+		return nil, fmt.Errorf("creating unpacker: %w", err)
+	}
+
+	return &Runtime{VM: vm}, nil
+}
+
+// Register registers the JS constructor for name, class is a JS expression
+// evaluating to a class or function, like `class Album { constructor(name) { this.name = name; } }`.
+func (r *Runtime) Register(name string, class string) error {
+	var constructor, err = r.VM.RunString("(" + class + ")")
+	if err != nil {
+		return fmt.Errorf("evaluating constructor %q: %w", name, err)
+	}
+
+	if _, ok := goja.AssertFunction(constructor); !ok {
+		return fmt.Errorf("constructor %q is not a function: %v", name, constructor)
+	}
+
+	return r.call("register", r.VM.ToValue(name), constructor)
+}
+
+// RegisterAll registers the JS constructors in sorted order of their names.
+func (r *Runtime) RegisterAll(classes map[string]string) error {
+	var names = make([]string, 0, len(classes))
+	for name := range classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := r.Register(name, classes[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unpack unpacks the packed JSON in the runtime.
+func (r *Runtime) Unpack(packed string) (*Result, error) {
+	var jsonObj = r.VM.Get("JSON").ToObject(r.VM)
+	var parse, _ = goja.AssertFunction(jsonObj.Get("parse"))
+	var parsed, err = parse(jsonObj, r.VM.ToValue(packed))
+	if err != nil {
+		return nil, fmt.Errorf("parsing packed value: %w", err)
+	}
+
+	var telepathObj = r.VM.Get("TELEPATH").ToObject(r.VM)
+	var unpack, _ = goja.AssertFunction(telepathObj.Get("unpack"))
+	value, err := unpack(telepathObj, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("unpacking: %w", err)
+	}
+
+	return &Result{
+		JSON:    packed,
+		Value:   value,
+		Runtime: r,
+	}, nil
+}
+
+// PackUnpack packs the value with the context and unpacks it in the runtime.
+func (r *Runtime) PackUnpack(ctx context.Context, jsContext *telepath.JSContext, value interface{}) (*Result, error) {
+	var packed, err = telepath.PackJSON(ctx, jsContext, value)
+	if err != nil {
+		return nil, err
+	}
+	return r.Unpack(packed)
+}
+
+func (r *Runtime) call(method string, args ...goja.Value) error {
+	var telepathObj = r.VM.Get("TELEPATH").ToObject(r.VM)
+	var fn, ok = goja.AssertFunction(telepathObj.Get(method))
+	if !ok {
+		return fmt.Errorf("TELEPATH.%s is not a function", method)
+	}
+	var _, err = fn(telepathObj, args...)
+	return err
+}
+
+// Result is a value which has been unpacked in a runtime.
+type Result struct {
+	JSON    string     // The packed JSON
+	Value   goja.Value // The unpacked value
+	Runtime *Runtime
+}
+
+// Eval evaluates a JS expression in which the unpacked value is available as `value`.
+func (r *Result) Eval(expr string) (goja.Value, error) {
+	var fnValue, err = r.Runtime.VM.RunString("(function (value) { return (" + expr + "); })")
+	if err != nil {
+		return nil, err
+	}
+	var fn, _ = goja.AssertFunction(fnValue)
+	return fn(goja.Undefined(), r.Value)
+}
+
+// Stringify returns the unpacked value encoded with JSON.stringify.
+func (r *Result) Stringify() (string, error) {
+	var value, err = r.Eval("JSON.stringify(value)")
+	if err != nil {
+		return "", err
+	}
+	if goja.IsUndefined(value) {
+		return "", fmt.Errorf("value cannot be encoded as JSON")
+	}
+	return value.String(), nil
+}
+
+// AssertRoundTrip packs the value with the registry, unpacks it in a new runtime with
+// jsClasses registered and checks the unpacked value against the value encoded by encoding/json.
+//
+// jsClasses maps constructor names to JS class expressions as accepted by Runtime.Register,
+// the classes should store their arguments in properties named like the fields encoded by encoding/json.
+func AssertRoundTrip(t testing.TB, reg *telepath.AdapterRegistry, value interface{}, jsClasses map[string]string) *Result {
+	t.Helper()
+
+	var rt, err = NewRuntime()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err = rt.RegisterAll(jsClasses); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	result, err := rt.PackUnpack(context.Background(), reg.Context(), value)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	unpacked, err := result.Stringify()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v (%v)", err, result.JSON)
+	}
+
+	expected, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !jsonEqual(expected, []byte(unpacked)) {
+		t.Errorf("Expected %s, got %s (%s)", expected, unpacked, result.JSON)
+	}
+
+	return result
+}
+
+func jsonEqual(a, b []byte) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,107 @@
+package telepathtest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Nigel2392/go-telepath/telepath"
+	"github.com/Nigel2392/go-telepath/telepath/telepathtest"
+)
+
+type Album struct {
+	Name    string
+	Artists []*Artist
+}
+
+type Artist struct {
+	Name string
+}
+
+var jsClasses = map[string]string{
+	"test.Album":  `class Album { constructor(name, artists) { this.Name = name; this.Artists = artists; } }`,
+	"test.Artist": `class Artist { constructor(name) { this.Name = name; } }`,
+}
+
+func init() {
+	telepath.Register(&telepath.ObjectAdapter[*Album]{
+		JSConstructor: "test.Album",
+		GetJSArgs: func(obj *Album) []interface{} {
+			return []interface{}{obj.Name, obj.Artists}
+		},
+	}, &Album{})
+	telepath.Register(&telepath.ObjectAdapter[*Artist]{
+		JSConstructor: "test.Artist",
+		GetJSArgs: func(obj *Artist) []interface{} {
+			return []interface{}{obj.Name}
+		},
+	}, &Artist{})
+}
+
+func TestAssertRoundTrip(t *testing.T) {
+	var artist = &Artist{Name: "This artist name is long enough to be referenced"}
+	var value = []interface{}{
+		&Album{Name: "Album", Artists: []*Artist{artist, artist}},
+		map[string]interface{}{"count": 2, "tags": []string{"a", "b"}},
+		nil,
+	}
+
+	var result = telepathtest.AssertRoundTrip(t, telepath.GlobalRegistry, value, jsClasses)
+
+	var chk, err = result.Eval(`value[0] instanceof TELEPATH.constructors["test.Album"] && value[0].Artists[0] === value[0].Artists[1]`)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+		return
+	}
+
+	if !chk.ToBoolean() {
+		t.Errorf("Expected true, got %v (%v)", chk, result.JSON)
+	}
+}
+
+func TestRuntime(t *testing.T) {
+	var rt, err = telepathtest.NewRuntime()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	t.Run("TestUnknownConstructor", func(t *testing.T) {
+		var _, err = rt.PackUnpack(context.Background(), telepath.NewContext(), &Artist{Name: "Artist"})
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("TestNotAFunction", func(t *testing.T) {
+		if err := rt.Register("test.Artist", `42`); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+
+	t.Run("TestRegister", func(t *testing.T) {
+		if err := rt.RegisterAll(jsClasses); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		var result, err = rt.PackUnpack(context.Background(), telepath.NewContext(), &Artist{Name: "Artist"})
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		if result.Value.ToObject(rt.VM).Get("Name").String() != "Artist" {
+			t.Errorf("Expected Artist, got %v", result.Value)
+		}
+	})
+}
+
+func TestGolden(t *testing.T) {
+	var artist = &Artist{Name: "This artist name is long enough to be referenced"}
+	var value = map[string]interface{}{
+		"album":  &Album{Name: "Album", Artists: []*Artist{artist, artist}},
+		"counts": map[string]int{"b": 2, "a": 1},
+		"name":   artist.Name,
+	}
+
+	telepathtest.Golden(t, "album", value)
+}
@@ -0,0 +1,221 @@
+package telepath
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const typeScriptHeader = `// Code generated by telepath-gen. DO NOT EDIT.
+
+/* Augment this interface to declare the value unpacked for a constructor. */
+export interface TelepathObjects {}
+
+export type TelepathObject<K extends string> = K extends keyof TelepathObjects ? TelepathObjects[K] : unknown;
+
+`
+
+const typeScriptFooter = `
+export type TelepathConstructors = {
+    [K in keyof TelepathArgs]: new (...args: TelepathArgs[K]) => TelepathObject<K>;
+};
+`
+
+// TypeScriptAdapter is implemented by adapters which know the TypeScript type of the values they pack.
+type TypeScriptAdapter interface {
+	TypeScriptType() string
+}
+
+// Constructors returns the constructor adapters in the registry keyed by their JS constructor name.
+//
+// An error is returned if different adapters describe different arguments for the same constructor.
+func (r *AdapterRegistry) Constructors() (map[string]ConstructorAdapter, error) {
+	var constructors = make(map[string]ConstructorAdapter)
+	var add = func(a Adapter) error {
+		var adapter, ok = a.(ConstructorAdapter)
+		if !ok {
+			return nil
+		}
+
+		var name, args = adapter.Constructor()
+		if name == "" {
+			return nil
+		}
+
+		if existing, ok := constructors[name]; ok {
+			if _, existingArgs := existing.Constructor(); !reflect.DeepEqual(existingArgs, args) {
+				return fmt.Errorf("conflicting arguments for JS constructor %q", name)
+			}
+			return nil
+		}
+
+		constructors[name] = adapter
+		return nil
+	}
+
+	for _, adapters := range r.adapters {
+		for _, a := range adapters {
+			if err := add(a); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, a := range r.iFaces {
+		if err := add(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return constructors, nil
+}
+
+// WriteTypeScript writes a TypeScript declaration file describing the arguments
+// of every JS constructor in the registry.
+//
+// Constructors without an argument schema accept any arguments.
+func (r *AdapterRegistry) WriteTypeScript(w io.Writer) error {
+	var constructors, err = r.Constructors()
+	if err != nil {
+		return err
+	}
+
+	var names = make([]string, 0, len(constructors))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(typeScriptHeader)
+	b.WriteString("export interface TelepathArgs {\n")
+	for _, name := range names {
+		var _, args = constructors[name].Constructor()
+		fmt.Fprintf(&b, "    %s: %s;\n", strconv.Quote(name), r.typeScriptArgs(args))
+	}
+	b.WriteString("}\n")
+	b.WriteString(typeScriptFooter)
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func (r *AdapterRegistry) typeScriptArgs(args []JSArg) string {
+	if args == nil {
+		return "any[]"
+	}
+
+	return "[" + strings.Join(r.typeScriptMembers(args), ", ") + "]"
+}
+
+// typeScriptMembers returns the arguments as tuple members or object properties.
+func (r *AdapterRegistry) typeScriptMembers(args []JSArg) []string {
+	var members = make([]string, 0, len(args))
+	for _, arg := range args {
+		var typ string
+		if arg.Fields != nil {
+			typ = "{" + joinMembers(r.typeScriptMembers(arg.Fields)) + "}"
+		} else {
+			typ = r.typeScriptType(arg.Type, nil)
+		}
+
+		if arg.Optional {
+			members = append(members, fmt.Sprintf("%s?: %s", arg.Name, nullable(typ)))
+		} else {
+			members = append(members, fmt.Sprintf("%s: %s", arg.Name, typ))
+		}
+	}
+	return members
+}
+
+// typeScriptType returns the type a value of type t is unpacked as, nil pointers are unpacked as null.
+//
+// visiting holds the types currently being described, recursive types are described as any.
+func (r *AdapterRegistry) typeScriptType(t reflect.Type, visiting map[reflect.Type]bool) string {
+	var typ = r.typeScriptValueType(t, visiting)
+	if t != nil && t.Kind() == reflect.Ptr && typ != "any" {
+		return nullable(typ)
+	}
+	return typ
+}
+
+func (r *AdapterRegistry) typeScriptValueType(t reflect.Type, visiting map[reflect.Type]bool) string {
+	if t == nil || visiting[t] {
+		return "any"
+	}
+
+	var a, _ = r.Resolve(t)
+	switch adapter := a.(type) {
+	case TypeScriptAdapter:
+		return adapter.TypeScriptType()
+	case ConstructorAdapter:
+		if name, _ := adapter.Constructor(); name != "" {
+			return fmt.Sprintf("TelepathObject<%s>", strconv.Quote(name))
+		}
+	case *StringTelepathAdapter, *UUIDTelepathAdapter:
+		return "string"
+	case *BytesTelepathAdapter:
+		return bytesTypeScriptType(adapter.Encoding)
+	case *ArrayTelepathAdapter:
+		if t.Elem().Kind() == reflect.Uint8 && adapter.ByteEncoding != BYTE_ENCODING_LIST {
+			return bytesTypeScriptType(adapter.ByteEncoding)
+		}
+	case *ErrorTelepathAdapter, *ValidationErrorTelepathAdapter, *LazyTelepathAdapter, *LoadTelepathAdapter:
+		return "any"
+	}
+
+	if visiting == nil {
+		visiting = make(map[reflect.Type]bool)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Ptr:
+		return r.typeScriptValueType(t.Elem(), visiting)
+	case reflect.Slice, reflect.Array:
+		var elem = r.typeScriptType(t.Elem(), visiting)
+		if strings.Contains(elem, " | ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case reflect.Map:
+		return fmt.Sprintf("{[key: string]: %s}", r.typeScriptType(t.Elem(), visiting))
+	}
+
+	return "any"
+}
+
+func nullable(typ string) string {
+	if strings.HasSuffix(typ, " | null") {
+		return typ
+	}
+	return typ + " | null"
+}
+
+func joinMembers(members []string) string {
+	return strings.Join(members, "; ")
+}
+
+func bytesTypeScriptType(encoding ByteEncoding) string {
+	switch encoding {
+	case BYTE_ENCODING_LIST:
+		return "number[]"
+	case BYTE_ENCODING_UINT8ARRAY:
+		return "Uint8Array"
+	case BYTE_ENCODING_RAW_JSON:
+		return "any"
+	}
+	return "string"
+}
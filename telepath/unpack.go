@@ -0,0 +1,296 @@
+package telepath
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// UnpackFunc builds the Go value for a JS constructor from its unpacked arguments.
+type UnpackFunc func(args []interface{}) (interface{}, error)
+
+// UnpackedObject is returned for constructors which are not registered on the Unpacker.
+type UnpackedObject struct {
+	Type string
+	Args []interface{}
+}
+
+// Unpacker is the Go counterpart of the JS unpacker.
+//
+// Lists are unpacked as []interface{} and dicts as map[string]interface{},
+// values which were shared when packing are shared again after unpacking.
+type Unpacker struct {
+	Constructors map[string]UnpackFunc
+}
+
+func NewUnpacker() *Unpacker {
+	return &Unpacker{
+		Constructors: make(map[string]UnpackFunc),
+	}
+}
+
+func (u *Unpacker) Register(name string, fn UnpackFunc) {
+	u.Constructors[name] = fn
+}
+
+// UnpackJSON decodes and unpacks packed JSON, numbers are decoded as float64.
+func (u *Unpacker) UnpackJSON(data []byte) (interface{}, error) {
+	var packed interface{}
+	if err := json.Unmarshal(data, &packed); err != nil {
+		return nil, err
+	}
+	return u.Unpack(packed)
+}
+
+// UnpackEncoded decodes and unpacks a value encoded by PackEncoded.
+func (u *Unpacker) UnpackEncoded(data []byte, decoder Decoder) (interface{}, error) {
+	var packed, err = decoder.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return u.Unpack(packed)
+}
+
+// Unpack unpacks a value decoded from the verbose or compact format.
+func (u *Unpacker) Unpack(packed interface{}) (interface{}, error) {
+	packed, err := expandEnvelope(packed)
+	if err != nil {
+		return nil, err
+	}
+
+	var state = &unpackState{
+		unpacker: u,
+		packed:   make(map[int]map[string]interface{}),
+		values:   make(map[int]interface{}),
+		busy:     make(map[int]bool),
+	}
+	if err := state.scan(packed); err != nil {
+		return nil, err
+	}
+	return state.unpack(packed)
+}
+
+type unpackState struct {
+	unpacker *Unpacker
+	packed   map[int]map[string]interface{}
+	values   map[int]interface{}
+	busy     map[int]bool // values which are being unpacked, a reference to these is a cycle
+}
+
+func isReserved(obj map[string]interface{}) bool {
+	for _, key := range DICT_RESERVED_KEYS {
+		if _, ok := obj[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scan indexes all values with an _id, so references can be resolved before their value is unpacked.
+func (s *unpackState) scan(packed interface{}) error {
+	switch v := packed.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if err := s.scan(item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		if !isReserved(v) {
+			for _, item := range v {
+				if err := s.scan(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if rawID, ok := v["_id"]; ok {
+			var id, err = unpackID(rawID)
+			if err != nil {
+				return err
+			}
+			s.packed[id] = v
+		}
+
+		for _, key := range []string{"_list", "_args"} {
+			if items, ok := v[key]; ok {
+				if err := s.scan(items); err != nil {
+					return err
+				}
+			}
+		}
+
+		if dict, ok := v["_dict"].(map[string]interface{}); ok {
+			for _, item := range dict {
+				if err := s.scan(item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *unpackState) unpack(packed interface{}) (interface{}, error) {
+	switch v := packed.(type) {
+	case []interface{}:
+		return s.unpackList(v)
+	case map[string]interface{}:
+		return s.unpackObject(v)
+	}
+	return packed, nil
+}
+
+func (s *unpackState) unpackList(packed []interface{}) ([]interface{}, error) {
+	var result = make([]interface{}, len(packed))
+	for i, item := range packed {
+		var value, err = s.unpack(item)
+		if err != nil {
+			return nil, wrapPathError(err, i)
+		}
+		result[i] = value
+	}
+	return result, nil
+}
+
+func (s *unpackState) unpackDict(packed map[string]interface{}) (map[string]interface{}, error) {
+	var result = make(map[string]interface{}, len(packed))
+	for key, item := range packed {
+		var value, err = s.unpack(item)
+		if err != nil {
+			return nil, wrapPathError(err, key)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+func (s *unpackState) unpackObject(obj map[string]interface{}) (interface{}, error) {
+	if !isReserved(obj) {
+		return s.unpackDict(obj)
+	}
+
+	if rawRef, ok := obj["_ref"]; ok {
+		var id, err = unpackID(rawRef)
+		if err != nil {
+			return nil, err
+		}
+		if value, ok := s.values[id]; ok {
+			return value, nil
+		}
+		if s.busy[id] {
+			return nil, fmt.Errorf("cyclic reference to _id %d", id)
+		}
+		var packed, found = s.packed[id]
+		if !found {
+			return nil, fmt.Errorf("reference to unknown _id %d", id)
+		}
+		return s.unpackObject(packed)
+	}
+
+	var id int
+	if rawID, ok := obj["_id"]; ok {
+		var err error
+		if id, err = unpackID(rawID); err != nil {
+			return nil, err
+		}
+		// Dicts are unpacked in random order, a reference might have been unpacked first.
+		if value, ok := s.values[id]; ok {
+			return value, nil
+		}
+		s.busy[id] = true
+		defer delete(s.busy, id)
+	}
+
+	var (
+		result interface{}
+		err    error
+	)
+
+	if val, ok := obj["_val"]; ok {
+		result = val
+	} else if list, ok := obj["_list"]; ok {
+		var items, isList = list.([]interface{})
+		if !isList {
+			return nil, fmt.Errorf("_list is not a list: %T", list)
+		}
+		if result, err = s.unpackList(items); err != nil {
+			return nil, wrapPathError(err, "_list")
+		}
+	} else if dict, ok := obj["_dict"]; ok {
+		var items, isDict = dict.(map[string]interface{})
+		if !isDict {
+			return nil, fmt.Errorf("_dict is not a dict: %T", dict)
+		}
+		if result, err = s.unpackDict(items); err != nil {
+			return nil, wrapPathError(err, "_dict")
+		}
+	} else if typ, ok := obj["_type"]; ok {
+		if result, err = s.unpackType(typ, obj["_args"]); err != nil {
+			return nil, err
+		}
+	} else if id != 0 {
+		return nil, fmt.Errorf("value with _id %d has no type", id)
+	}
+
+	if id != 0 {
+		s.values[id] = result
+	}
+
+	return result, nil
+}
+
+func (s *unpackState) unpackType(typ interface{}, rawArgs interface{}) (interface{}, error) {
+	var name, ok = typ.(string)
+	if !ok {
+		return nil, fmt.Errorf("_type is not a string: %T", typ)
+	}
+
+	var packedArgs []interface{}
+	if rawArgs != nil {
+		if packedArgs, ok = rawArgs.([]interface{}); !ok {
+			return nil, fmt.Errorf("_args of %s is not a list: %T", name, rawArgs)
+		}
+	}
+
+	var args, err = s.unpackList(packedArgs)
+	if err != nil {
+		return nil, wrapPathError(err, "_args")
+	}
+
+	if fn, ok := s.unpacker.Constructors[name]; ok {
+		return fn(args)
+	}
+
+	return &UnpackedObject{Type: name, Args: args}, nil
+}
+
+// unpackID converts an _id or _ref to an int, decoders produce different number types.
+func unpackID(raw interface{}) (int, error) {
+	if n, ok := raw.(json.Number); ok {
+		var id, err = n.Int64()
+		if err == nil {
+			return int(id), nil
+		}
+	}
+
+	var rVal = reflect.ValueOf(raw)
+	switch rVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rVal.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(rVal.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if f := rVal.Float(); f == float64(int(f)) {
+			return int(f), nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid _id or _ref: %v", raw)
+}
+
+// UnpackJSON unpacks packed JSON without any registered constructors.
+func UnpackJSON(data []byte) (interface{}, error) {
+	return NewUnpacker().UnpackJSON(data)
+}
@@ -0,0 +1,186 @@
+package telepath
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const VALIDATION_ERROR_JS_CONSTRUCTOR = "telepath.ValidationError"
+
+// ValidationError is a structured error which keeps its code, params and the errors of its children.
+//
+// Fields holds errors keyed by field name, Items holds errors keyed by list index.
+type ValidationError struct {
+	Message string
+	Code    string
+	Params  map[string]interface{}
+	Fields  map[string]error
+	Items   map[int]error
+}
+
+func (e *ValidationError) Error() string {
+	var parts = make([]string, 0, 1+len(e.Fields)+len(e.Items))
+	if e.Message != "" {
+		parts = append(parts, e.Message)
+	}
+
+	for _, key := range sortedKeys(e.Fields) {
+		parts = append(parts, fmt.Sprintf("%s: %v", key, e.Fields[key]))
+	}
+
+	for _, index := range sortedKeys(e.Items) {
+		parts = append(parts, fmt.Sprintf("[%d]: %v", index, e.Items[index]))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// Unwrap returns the errors of the fields and items, so errors.Is and errors.As can find them.
+func (e *ValidationError) Unwrap() []error {
+	var errs = make([]error, 0, len(e.Fields)+len(e.Items))
+	for _, key := range sortedKeys(e.Fields) {
+		errs = append(errs, e.Fields[key])
+	}
+	for _, index := range sortedKeys(e.Items) {
+		errs = append(errs, e.Items[index])
+	}
+	return errs
+}
+
+// validationErrorData is the flattened form of an error tree,
+// errors.Join and wrapped errors are merged into a single level.
+type validationErrorData struct {
+	messages []*ValidationError
+	fields   map[string][]error
+	items    map[int][]error
+}
+
+func (d *validationErrorData) collect(err error) {
+	switch e := err.(type) {
+	case nil:
+		return
+	case *ValidationError:
+		if e.Message != "" || e.Code != "" {
+			d.messages = append(d.messages, e)
+		}
+		for key, fieldErr := range e.Fields {
+			if fieldErr != nil {
+				d.fields[key] = append(d.fields[key], fieldErr)
+			}
+		}
+		for index, itemErr := range e.Items {
+			if itemErr != nil {
+				d.items[index] = append(d.items[index], itemErr)
+			}
+		}
+		return
+	case interface{ Unwrap() []error }:
+		for _, child := range e.Unwrap() {
+			d.collect(child)
+		}
+		return
+	}
+
+	// Wrapped errors are packed as the structured error they wrap, the message added
+	// by wrapping is dropped. Other errors become a single message.
+	for wrapped := errors.Unwrap(err); wrapped != nil; wrapped = errors.Unwrap(wrapped) {
+		switch wrapped.(type) {
+		case *ValidationError, interface{ Unwrap() []error }:
+			d.collect(wrapped)
+			return
+		}
+	}
+
+	d.messages = append(d.messages, &ValidationError{Message: err.Error()})
+}
+
+// ValidationErrorTelepathAdapter packs errors as VALIDATION_ERROR_JS_CONSTRUCTOR objects.
+//
+// The arguments are a list of {message, code, params} dicts, a dict of field errors
+// and a dict of item errors keyed by index, compatible with Wagtail's blockErrors.
+type ValidationErrorTelepathAdapter struct{}
+
+func ValidationErrorAdapter() *ValidationErrorTelepathAdapter {
+	return &ValidationErrorTelepathAdapter{}
+}
+
+func (m *ValidationErrorTelepathAdapter) BuildNode(ctx context.Context, value any, c Context) (Node, error) {
+	var err, ok = value.(error)
+	if !ok {
+		return nil, fmt.Errorf("value is not an error: %T", value)
+	}
+	return m.buildErrorNode(ctx, err, c)
+}
+
+func (m *ValidationErrorTelepathAdapter) buildErrorNode(ctx context.Context, err error, c Context) (Node, error) {
+	var data = &validationErrorData{
+		fields: make(map[string][]error),
+		items:  make(map[int][]error),
+	}
+	data.collect(err)
+
+	// Keys are built in sorted order, so the _id numbering is the same on every pack.
+	var messages = make([]Node, 0, len(data.messages))
+	for i, msg := range data.messages {
+		var params = make(map[string]Node, len(msg.Params))
+		for _, key := range sortedKeys(msg.Params) {
+			var node, err = c.BuildNode(ctx, msg.Params[key])
+			if err != nil {
+				return nil, wrapPath(err, "_args", 0, i, "params", key)
+			}
+			params[key] = node
+		}
+
+		messages = append(messages, NewDictNode(map[string]Node{
+			"message": NewStringNode(msg.Message),
+			"code":    NewStringNode(msg.Code),
+			"params":  NewDictNode(params),
+		}))
+	}
+
+	var fields = make(map[string]Node, len(data.fields))
+	for _, key := range sortedKeys(data.fields) {
+		var node, err = m.buildErrorNode(ctx, errors.Join(data.fields[key]...), c)
+		if err != nil {
+			return nil, wrapPath(err, "_args", 1, key)
+		}
+		fields[key] = node
+	}
+
+	var items = make(map[string]Node, len(data.items))
+	for _, index := range sortedKeys(data.items) {
+		var node, err = m.buildErrorNode(ctx, errors.Join(data.items[index]...), c)
+		if err != nil {
+			return nil, wrapPath(err, "_args", 2, strconv.Itoa(index))
+		}
+		items[strconv.Itoa(index)] = node
+	}
+
+	return NewObjectNode(VALIDATION_ERROR_JS_CONSTRUCTOR, []Node{
+		NewListNode(messages),
+		NewDictNode(fields),
+		NewDictNode(items),
+	}), nil
+}
+
+// wrapPath prepends the segments to the path of err.
+func wrapPath(err error, path ...interface{}) error {
+	for i := len(path) - 1; i >= 0; i-- {
+		err = wrapPathError(err, path[i])
+	}
+	return err
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	var keys = make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}